@@ -0,0 +1,861 @@
+package sshconfig
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidInventory is returned for Ansible inventories that cannot be read
+var ErrInvalidInventory = errors.New("sshconfig: invalid Ansible inventory")
+
+// InventoryINI is the INI format of Ansible inventories
+const InventoryINI InventoryFormat = "ini"
+
+// Groups Ansible creates for every inventory
+const (
+	ansibleAll       = "all"
+	ansibleUngrouped = "ungrouped"
+)
+
+// groupsComment starts the comment that lists the Ansible groups of a host
+const groupsComment = "# groups:"
+
+// ansibleInventory is an Ansible inventory, with hosts in the order they
+// first appear
+type ansibleInventory struct {
+	hosts    []string
+	hostVars map[string]map[string]string
+	groups   map[string]*ansibleGroup
+}
+
+type ansibleGroup struct {
+	hosts    []string
+	children []string
+	vars     map[string]string
+}
+
+func newAnsibleInventory() *ansibleInventory {
+	return &ansibleInventory{
+		hostVars: map[string]map[string]string{},
+		groups:   map[string]*ansibleGroup{},
+	}
+}
+
+func (inventory *ansibleInventory) group(name string) *ansibleGroup {
+	group, ok := inventory.groups[name]
+	if !ok {
+		group = &ansibleGroup{vars: map[string]string{}}
+		inventory.groups[name] = group
+	}
+	return group
+}
+
+// addHost adds a host to a group, merging vars into its host vars
+func (inventory *ansibleInventory) addHost(group, host string, vars map[string]string) {
+
+	if _, ok := inventory.hostVars[host]; !ok {
+		inventory.hosts = append(inventory.hosts, host)
+		inventory.hostVars[host] = map[string]string{}
+	}
+	for name, value := range vars {
+		inventory.hostVars[host][name] = value
+	}
+
+	g := inventory.group(group)
+	for _, existing := range g.hosts {
+		if existing == host {
+			return
+		}
+	}
+	g.hosts = append(g.hosts, host)
+
+}
+
+// LoadAnsibleInventory reads an Ansible inventory file, choosing the
+// format from its extension: .yaml and .yml files are YAML, any other
+// file is INI, like the usual hosts file
+func LoadAnsibleInventory(path string) (*Config, error) {
+
+	format := InventoryINI
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = InventoryYAML
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ImportAnsible(f, format)
+
+}
+
+// ImportAnsible reads an Ansible inventory in INI or YAML format and
+// returns a config with a Host block for each inventory host, in the
+// order they first appear
+// Variables are merged like Ansible does: host variables win over those
+// of child groups, which win over those of their parents and finally of
+// the all group. The connection variables are mapped to parameters:
+//
+//	ansible_host                  HostName
+//	ansible_user                  User
+//	ansible_port                  Port
+//	ansible_ssh_private_key_file  IdentityFile
+//	ansible_ssh_common_args       the options it holds, such as -J or -o
+//	ansible_ssh_extra_args        the same
+//
+// along with their older ansible_ssh_ spellings. Other variables are
+// ignored. The groups of each host, other than all and ungrouped, are
+// listed in a comment on its block, such as "# groups: db prod", which
+// ExportAnsible reads back. Options that cannot be converted are kept
+// in a comment as well
+func ImportAnsible(r io.Reader, format InventoryFormat) (*Config, error) {
+
+	var inventory *ansibleInventory
+	var err error
+	switch format {
+	case InventoryINI:
+		inventory, err = parseAnsibleINI(r)
+	case InventoryYAML:
+		inventory, err = parseAnsibleYAML(r)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	for _, name := range inventory.hosts {
+		host, err := inventory.host(name)
+		if err != nil {
+			return nil, err
+		}
+		config.Hosts = append(config.Hosts, host)
+	}
+
+	return config, nil
+
+}
+
+// parseAnsibleINI reads an inventory in the INI format
+func parseAnsibleINI(r io.Reader) (*ansibleInventory, error) {
+
+	inventory := newAnsibleInventory()
+	group, kind := ansibleUngrouped, "hosts"
+	lineNumber := 0
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+
+		lineNumber++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidInventory, lineNumber, line)
+			}
+			group, kind, _ = strings.Cut(line[1:len(line)-1], ":")
+			if kind == "" {
+				kind = "hosts"
+			}
+			if kind != "hosts" && kind != "vars" && kind != "children" {
+				return nil, fmt.Errorf("%w: line %d: unknown section type %s", ErrInvalidInventory, lineNumber, kind)
+			}
+			inventory.group(group)
+			continue
+		}
+
+		switch kind {
+		case "vars":
+			name, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("%w: line %d: expected name=value", ErrInvalidInventory, lineNumber)
+			}
+			inventory.group(group).vars[strings.TrimSpace(name)] = unquoteAnsible(strings.TrimSpace(value))
+		case "children":
+			g := inventory.group(group)
+			g.children = append(g.children, line)
+			inventory.group(line)
+		default:
+			words, err := splitShellWords(line)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidInventory, lineNumber, err)
+			}
+			vars := map[string]string{}
+			for _, word := range words[1:] {
+				name, value, ok := strings.Cut(word, "=")
+				if !ok {
+					return nil, fmt.Errorf("%w: line %d: expected name=value, got %s", ErrInvalidInventory, lineNumber, word)
+				}
+				vars[name] = value
+			}
+			names, err := expandHostRange(words[0])
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidInventory, lineNumber, err)
+			}
+			for _, name := range names {
+				inventory.addHost(group, name, vars)
+			}
+		}
+
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return inventory, nil
+
+}
+
+// parseAnsibleYAML reads an inventory in the YAML format, where the top
+// level maps group names to their hosts, vars and children
+func parseAnsibleYAML(r io.Reader) (*ansibleInventory, error) {
+
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	inventory := newAnsibleInventory()
+	if len(doc.Content) == 0 {
+		return inventory, nil
+	}
+
+	err := eachYAMLEntry(doc.Content[0], func(name string, value *yaml.Node) error {
+		return inventory.parseYAMLGroup(name, value)
+	})
+
+	return inventory, err
+
+}
+
+func (inventory *ansibleInventory) parseYAMLGroup(name string, node *yaml.Node) error {
+
+	group := inventory.group(name)
+
+	return eachYAMLEntry(node, func(key string, value *yaml.Node) error {
+		switch key {
+		case "hosts":
+			return eachYAMLEntry(value, func(host string, varsNode *yaml.Node) error {
+				vars, err := yamlVars(varsNode)
+				if err != nil {
+					return err
+				}
+				names, err := expandHostRange(host)
+				if err != nil {
+					return fmt.Errorf("%w: %v", ErrInvalidInventory, err)
+				}
+				for _, host := range names {
+					inventory.addHost(name, host, vars)
+				}
+				return nil
+			})
+		case "vars":
+			vars, err := yamlVars(value)
+			if err != nil {
+				return err
+			}
+			for name, value := range vars {
+				group.vars[name] = value
+			}
+			return nil
+		case "children":
+			return eachYAMLEntry(value, func(child string, childNode *yaml.Node) error {
+				group.children = append(group.children, child)
+				return inventory.parseYAMLGroup(child, childNode)
+			})
+		}
+		return fmt.Errorf("%w: unknown key %s in group %s", ErrInvalidInventory, key, name)
+	})
+
+}
+
+// eachYAMLEntry calls fn for every entry of a mapping, in order; an
+// empty value is treated as an empty mapping
+func eachYAMLEntry(node *yaml.Node, fn func(key string, value *yaml.Node) error) error {
+
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("%w: line %d: expected a mapping", ErrInvalidInventory, node.Line)
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if err := fn(node.Content[i].Value, node.Content[i+1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// yamlVars returns the scalar variables of a mapping; variables holding
+// lists or mappings cannot configure ssh and are skipped
+func yamlVars(node *yaml.Node) (map[string]string, error) {
+	vars := map[string]string{}
+	err := eachYAMLEntry(node, func(name string, value *yaml.Node) error {
+		if value.Kind == yaml.ScalarNode && value.Tag != "!!null" {
+			vars[name] = value.Value
+		}
+		return nil
+	})
+	return vars, err
+}
+
+// host builds the block of an inventory host
+func (inventory *ansibleInventory) host(name string) (*Host, error) {
+
+	groups := inventory.groupsOf(name)
+	vars := map[string]string{}
+	for _, group := range groups {
+		for key, value := range inventory.groups[group].vars {
+			vars[key] = value
+		}
+	}
+	for key, value := range inventory.hostVars[name] {
+		vars[key] = value
+	}
+
+	var comments []string
+	var listed []string
+	for _, group := range groups {
+		if group != ansibleAll && group != ansibleUngrouped {
+			listed = append(listed, group)
+		}
+	}
+	if len(listed) > 0 {
+		sort.Strings(listed)
+		comments = append(comments, groupsComment+" "+strings.Join(listed, " "))
+	}
+
+	pattern, port := name, ""
+	if i := strings.LastIndex(name, ":"); i > 0 && strings.Count(name, ":") == 1 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			pattern, port = name[:i], name[i+1:]
+		}
+	}
+
+	host := NewHost([]string{pattern}, comments)
+	add := func(keyword string, names ...string) {
+		for _, name := range names {
+			if value := vars[name]; value != "" {
+				host.AddParam(NewParam(keyword, []string{value}, nil))
+				return
+			}
+		}
+	}
+	add(HostNameKeyword, "ansible_host", "ansible_ssh_host")
+	add(UserKeyword, "ansible_user", "ansible_ssh_user")
+	// a port given with the name is a host variable
+	hostVars := inventory.hostVars[name]
+	if port != "" && hostVars["ansible_port"] == "" && hostVars["ansible_ssh_port"] == "" {
+		vars["ansible_port"], vars["ansible_ssh_port"] = port, ""
+	}
+	add(PortKeyword, "ansible_port", "ansible_ssh_port")
+	add(IdentityFileKeyword, "ansible_ssh_private_key_file", "ansible_private_key_file")
+
+	for _, name := range []string{"ansible_ssh_common_args", "ansible_ssh_extra_args"} {
+		if vars[name] == "" {
+			continue
+		}
+		params, unconverted, err := parseSSHOptions(vars[name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s of %s: %v", ErrInvalidInventory, name, pattern, err)
+		}
+		for _, param := range params {
+			host.AddParam(param)
+		}
+		if len(unconverted) > 0 {
+			host.Comments = append(host.Comments, fmt.Sprintf("# %s not converted: %s", name, strings.Join(unconverted, " ")))
+		}
+	}
+
+	return host, nil
+
+}
+
+// groupsOf returns the groups of a host, including the groups they
+// belong to, in the order Ansible applies their variables: by depth
+// below the all group, then by name
+func (inventory *ansibleInventory) groupsOf(host string) []string {
+
+	parents := map[string][]string{}
+	for name, group := range inventory.groups {
+		for _, child := range group.children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+
+	member := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if member[name] {
+			return
+		}
+		member[name] = true
+		for _, parent := range parents[name] {
+			visit(parent)
+		}
+	}
+	for name, group := range inventory.groups {
+		for _, h := range group.hosts {
+			if h == host {
+				visit(name)
+			}
+		}
+	}
+	if _, ok := inventory.groups[ansibleAll]; ok {
+		member[ansibleAll] = true
+	}
+
+	depths := map[string]int{}
+	var depth func(name string, seen map[string]bool) int
+	depth = func(name string, seen map[string]bool) int {
+		if d, ok := depths[name]; ok {
+			return d
+		}
+		if name == ansibleAll || seen[name] {
+			return 0
+		}
+		seen[name] = true
+		d := 1
+		for _, parent := range parents[name] {
+			if p := depth(parent, seen) + 1; p > d {
+				d = p
+			}
+		}
+		depths[name] = d
+		return d
+	}
+
+	var groups []string
+	for name := range member {
+		groups = append(groups, name)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		di, dj := depth(groups[i], map[string]bool{}), depth(groups[j], map[string]bool{})
+		if di != dj {
+			return di < dj
+		}
+		return groups[i] < groups[j]
+	})
+
+	return groups
+
+}
+
+// expandHostRange expands the ranges in an inventory host name, such
+// as web[01:10] or db-[a:c], into the names they stand for
+func expandHostRange(name string) ([]string, error) {
+
+	start := strings.Index(name, "[")
+	if start < 0 {
+		return []string{name}, nil
+	}
+	end := strings.Index(name[start:], "]")
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated range in %s", name)
+	}
+	end += start
+
+	bounds := strings.Split(name[start+1:end], ":")
+	if len(bounds) < 2 || len(bounds) > 3 {
+		return nil, fmt.Errorf("invalid range in %s", name)
+	}
+	step := 1
+	if len(bounds) == 3 {
+		var err error
+		if step, err = strconv.Atoi(bounds[2]); err != nil || step < 1 {
+			return nil, fmt.Errorf("invalid range step in %s", name)
+		}
+	}
+
+	var items []string
+	if from, err := strconv.Atoi(bounds[0]); err == nil {
+		to, err := strconv.Atoi(bounds[1])
+		if err != nil || to < from {
+			return nil, fmt.Errorf("invalid range in %s", name)
+		}
+		width := 0
+		if strings.HasPrefix(bounds[0], "0") {
+			width = len(bounds[0])
+		}
+		for n := from; n <= to; n += step {
+			items = append(items, fmt.Sprintf("%0*d", width, n))
+		}
+	} else if len(bounds[0]) == 1 && len(bounds[1]) == 1 && bounds[0] <= bounds[1] {
+		for c := bounds[0][0]; c <= bounds[1][0]; c += byte(step) {
+			items = append(items, string(c))
+		}
+	} else {
+		return nil, fmt.Errorf("invalid range in %s", name)
+	}
+
+	rest, err := expandHostRange(name[end+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, item := range items {
+		for _, suffix := range rest {
+			names = append(names, name[:start]+item+suffix)
+		}
+	}
+
+	return names, nil
+
+}
+
+// unquoteAnsible removes the quotes around a value
+func unquoteAnsible(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// splitShellWords splits a command line into words like a POSIX shell,
+// honouring single and double quotes and backslash escapes
+func splitShellWords(s string) ([]string, error) {
+
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`+"`", runes[i+1]) {
+				i++
+				word.WriteRune(runes[i])
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == '\\' && i+1 < len(runes):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+
+}
+
+// parseSSHOptions converts the -o, -J, -i, -p and -l options of an ssh
+// command line into parameters, returning the words it could not convert
+func parseSSHOptions(s string) ([]*Param, []string, error) {
+
+	words, err := splitShellWords(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	flags := map[string]string{
+		"-J": ProxyJumpKeyword,
+		"-i": IdentityFileKeyword,
+		"-p": PortKeyword,
+		"-l": UserKeyword,
+	}
+
+	var params []*Param
+	var unconverted []string
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if len(word) < 2 || word[0] != '-' {
+			unconverted = append(unconverted, word)
+			continue
+		}
+		flag, value := word[:2], word[2:]
+		keyword, ok := flags[flag]
+		if !ok && flag != "-o" {
+			unconverted = append(unconverted, word)
+			continue
+		}
+		if value == "" {
+			if i+1 == len(words) {
+				return nil, nil, fmt.Errorf("%s needs a value", flag)
+			}
+			i++
+			value = words[i]
+		}
+		if flag != "-o" {
+			params = append(params, NewParam(keyword, []string{value}, nil))
+			continue
+		}
+		keyword, args := splitKeyword(value)
+		info, ok := LookupKeyword(keyword)
+		if !ok {
+			unconverted = append(unconverted, "-o", value)
+			continue
+		}
+		params = append(params, NewParam(info.Name, strings.Fields(args), nil))
+	}
+
+	return params, unconverted, nil
+
+}
+
+// ansibleVar is a host variable written by ExportAnsible
+type ansibleVar struct {
+	name, value string
+}
+
+// ansibleHost is a host written by ExportAnsible
+type ansibleHost struct {
+	name   string
+	groups []string
+	vars   []ansibleVar
+}
+
+// ExportAnsible writes the concrete hosts of the config as an Ansible
+// inventory in INI or YAML format
+// Every Host block with a pattern that has no wildcards contributes its
+// first such pattern, with the values ssh would use for it:
+//
+//	HostName      ansible_host
+//	User          ansible_user
+//	Port          ansible_port
+//	IdentityFile  ansible_ssh_private_key_file, the first one
+//	ProxyJump     ansible_ssh_common_args, as -J
+//
+// Hosts are put in the groups listed by a "# groups: a b" comment on
+// their block, as written by ImportAnsible, and left ungrouped otherwise.
+// Groups are written in lexical order and hosts in config order
+func (config *Config) ExportAnsible(w io.Writer, format InventoryFormat) error {
+
+	var hosts []*ansibleHost
+	seen := map[string]bool{}
+
+	for _, block := range config.Hosts {
+
+		name := concreteHostname(block)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+
+		host := &ansibleHost{name: name, groups: blockGroups(block)}
+		resolved := config.Resolve(name)
+		add := func(variable, keyword string, format func(string) string) {
+			if param := resolved.GetParam(keyword); param != nil && len(param.Args) > 0 {
+				if value := format(param.Value()); value != "" {
+					host.vars = append(host.vars, ansibleVar{variable, value})
+				}
+			}
+		}
+		same := func(value string) string { return value }
+		add("ansible_host", HostNameKeyword, func(hostname string) string {
+			return expandHostName(hostname, name)
+		})
+		add("ansible_user", UserKeyword, same)
+		add("ansible_port", PortKeyword, same)
+		add("ansible_ssh_private_key_file", IdentityFileKeyword, same)
+		add("ansible_ssh_common_args", ProxyJumpKeyword, func(jump string) string {
+			if strings.EqualFold(jump, "none") {
+				return ""
+			}
+			return "-J " + jump
+		})
+
+		hosts = append(hosts, host)
+
+	}
+
+	switch format {
+	case InventoryINI:
+		return writeAnsibleINI(w, hosts)
+	case InventoryYAML:
+		return writeAnsibleYAML(w, hosts)
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+
+}
+
+// blockGroups returns the groups listed in the comments of a block
+func blockGroups(block *Host) []string {
+	var groups []string
+	for _, comment := range block.Comments {
+		comment = strings.TrimSpace(comment)
+		if !strings.HasPrefix(comment, "#") {
+			comment = "# " + comment
+		}
+		if rest, ok := strings.CutPrefix(comment, groupsComment); ok {
+			groups = append(groups, strings.Fields(rest)...)
+		}
+	}
+	return groups
+}
+
+// ansibleGroups returns the names of the groups of hosts in lexical
+// order, along with the hosts of each
+func ansibleGroups(hosts []*ansibleHost) ([]string, map[string][]*ansibleHost) {
+
+	members := map[string][]*ansibleHost{}
+	var names []string
+	for _, host := range hosts {
+		for _, group := range host.groups {
+			if _, ok := members[group]; !ok {
+				names = append(names, group)
+			}
+			members[group] = append(members[group], host)
+		}
+	}
+	sort.Strings(names)
+
+	return names, members
+
+}
+
+func writeAnsibleINI(w io.Writer, hosts []*ansibleHost) error {
+
+	buf := &strings.Builder{}
+	line := func(host *ansibleHost) {
+		buf.WriteString(host.name)
+		for _, v := range host.vars {
+			fmt.Fprintf(buf, " %s=%s", v.name, quoteShellWord(v.value))
+		}
+		buf.WriteString("\n")
+	}
+
+	ungrouped := false
+	for _, host := range hosts {
+		if len(host.groups) == 0 {
+			line(host)
+			ungrouped = true
+		}
+	}
+
+	names, members := ansibleGroups(hosts)
+	for i, name := range names {
+		if i > 0 || ungrouped {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "[%s]\n", name)
+		for _, host := range members[name] {
+			line(host)
+		}
+	}
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+
+}
+
+func writeAnsibleYAML(w io.Writer, hosts []*ansibleHost) error {
+
+	scalar := func(value string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	}
+	mapping := func() *yaml.Node {
+		return &yaml.Node{Kind: yaml.MappingNode}
+	}
+	set := func(m *yaml.Node, key string, value *yaml.Node) {
+		m.Content = append(m.Content, scalar(key), value)
+	}
+	hostNodes := func(hosts []*ansibleHost) *yaml.Node {
+		m := mapping()
+		for _, host := range hosts {
+			vars := mapping()
+			for _, v := range host.vars {
+				value := scalar(v.value)
+				if _, err := strconv.Atoi(v.value); err != nil {
+					value.Tag = "!!str"
+				}
+				set(vars, v.name, value)
+			}
+			set(m, host.name, vars)
+		}
+		return m
+	}
+
+	all := mapping()
+	var ungrouped []*ansibleHost
+	for _, host := range hosts {
+		if len(host.groups) == 0 {
+			ungrouped = append(ungrouped, host)
+		}
+	}
+	if len(ungrouped) > 0 {
+		set(all, "hosts", hostNodes(ungrouped))
+	}
+
+	names, members := ansibleGroups(hosts)
+	if len(names) > 0 {
+		children := mapping()
+		for _, name := range names {
+			group := mapping()
+			set(group, "hosts", hostNodes(members[name]))
+			set(children, name, group)
+		}
+		set(all, "children", children)
+	}
+
+	doc := mapping()
+	set(doc, ansibleAll, all)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	return enc.Close()
+
+}
+
+// quoteShellWord quotes a value so splitShellWords reads it back as one word
+func quoteShellWord(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n'\"\\$`") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
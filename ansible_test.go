@@ -0,0 +1,314 @@
+package sshconfig
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ansibleINITest = `
+# production
+bastion.example.com ansible_user=jump
+
+[web]
+web[1:2].example.com ansible_host=10.0.0.1
+web3.example.com:2222 ansible_ssh_private_key_file=~/.ssh/web
+
+[db]
+db1 ansible_host=10.0.1.1 ansible_user=postgres ansible_ssh_common_args='-J bastion.example.com -o "ServerAliveInterval 30" -v'
+
+[web:vars]
+ansible_user=deploy
+
+[prod:children]
+web
+db
+
+[prod:vars]
+ansible_user=admin
+ansible_port=2200
+
+[all:vars]
+ansible_ssh_common_args="-o StrictHostKeyChecking=accept-new"
+`
+
+var ansibleYAMLTest = `
+all:
+  hosts:
+    bastion.example.com:
+      ansible_user: jump
+  vars:
+    ansible_ssh_common_args: -o StrictHostKeyChecking=accept-new
+  children:
+    prod:
+      vars:
+        ansible_user: admin
+        ansible_port: 2200
+      children:
+        web:
+          hosts:
+            web[1:2].example.com:
+              ansible_host: 10.0.0.1
+            web3.example.com:2222:
+              ansible_ssh_private_key_file: ~/.ssh/web
+          vars:
+            ansible_user: deploy
+        db:
+          hosts:
+            db1:
+              ansible_host: 10.0.1.1
+              ansible_user: postgres
+              ansible_ssh_common_args: -J bastion.example.com -o "ServerAliveInterval 30" -v
+`
+
+var ansibleConfigTest = `
+Host bastion.example.com
+  User jump
+  StrictHostKeyChecking accept-new
+
+# groups: prod web
+Host web1.example.com
+  HostName 10.0.0.1
+  User deploy
+  Port 2200
+  StrictHostKeyChecking accept-new
+
+# groups: prod web
+Host web2.example.com
+  HostName 10.0.0.1
+  User deploy
+  Port 2200
+  StrictHostKeyChecking accept-new
+
+# groups: prod web
+Host web3.example.com
+  User deploy
+  Port 2222
+  IdentityFile ~/.ssh/web
+  StrictHostKeyChecking accept-new
+
+# groups: db prod
+# ansible_ssh_common_args not converted: -v
+Host db1
+  HostName 10.0.1.1
+  User postgres
+  Port 2200
+  ProxyJump bastion.example.com
+  ServerAliveInterval 30
+`
+
+func hostsString(config *Config) string {
+	buf := &bytes.Buffer{}
+	for _, host := range config.Hosts {
+		buf.WriteString(host.String())
+	}
+	return buf.String()
+}
+
+func TestImportAnsibleINI(t *testing.T) {
+
+	config, err := ImportAnsible(strings.NewReader(ansibleINITest), InventoryINI)
+	assert.NoError(t, err)
+	assert.Equal(t, ansibleConfigTest, hostsString(config))
+}
+
+func TestImportAnsibleYAML(t *testing.T) {
+
+	config, err := ImportAnsible(strings.NewReader(ansibleYAMLTest), InventoryYAML)
+	assert.NoError(t, err)
+	assert.Equal(t, ansibleConfigTest, hostsString(config))
+}
+
+func TestLoadAnsibleInventory(t *testing.T) {
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "hosts"), []byte(ansibleINITest), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(ansibleYAMLTest), 0600))
+
+	fromINI, err := LoadAnsibleInventory(filepath.Join(dir, "hosts"))
+	assert.NoError(t, err)
+	fromYAML, err := LoadAnsibleInventory(filepath.Join(dir, "hosts.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, hostsString(fromINI), hostsString(fromYAML))
+}
+
+func TestImportAnsibleErrors(t *testing.T) {
+
+	for _, inventory := range []string{
+		"[web\nweb1\n",
+		"[web:hostvars]\n",
+		"[web:vars]\nansible_user\n",
+		"web1 ansible_user\n",
+		"web[1:]\n",
+		"web1 ansible_ssh_common_args='-J'\n",
+	} {
+		_, err := ImportAnsible(strings.NewReader(inventory), InventoryINI)
+		assert.ErrorIs(t, err, ErrInvalidInventory, inventory)
+	}
+
+	_, err := ImportAnsible(strings.NewReader("all:\n  hosts: [web1]\n"), InventoryYAML)
+	assert.ErrorIs(t, err, ErrInvalidInventory)
+	_, err = ImportAnsible(strings.NewReader(""), InventoryCSV)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestExpandHostRange(t *testing.T) {
+
+	names, err := expandHostRange("web[08:10:2]-[a:b]")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web08-a", "web08-b", "web10-a", "web10-b"}, names)
+}
+
+func TestSplitShellWords(t *testing.T) {
+
+	words, err := splitShellWords(`-o 'ProxyCommand ssh -W %h:%p' "a \"b\"" c\ d`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-o", "ProxyCommand ssh -W %h:%p", `a "b"`, "c d"}, words)
+
+	_, err = splitShellWords(`"open`)
+	assert.Error(t, err)
+}
+
+func TestParseSSHOptionsSeparators(t *testing.T) {
+
+	config, err := ImportAnsible(strings.NewReader(`web1 ansible_ssh_common_args='-o "User = bob" -o Port=2222 -o "Compression	yes"'`+"\n"), InventoryINI)
+	assert.NoError(t, err)
+	host := config.Hosts[0]
+	assert.Equal(t, []string{"bob"}, host.GetParam(UserKeyword).Args)
+	assert.Equal(t, []string{"2222"}, host.GetParam(PortKeyword).Args)
+	assert.Equal(t, []string{"yes"}, host.GetParam(CompressionKeyword).Args)
+}
+
+var ansibleExportConfigTest = `
+# groups: prod web
+Host web1 web1.example.com
+  HostName 10.0.0.1
+  User deploy
+  ProxyJump bastion
+
+Host bastion
+  HostName bastion.example.com
+  ProxyJump none
+
+# groups: db prod
+Host db? db1
+  HostName 10.0.1.1
+  IdentityFile ~/.ssh/db
+  IdentityFile ~/.ssh/other
+
+Match originalhost db1
+  Port 2200
+
+Host *
+  User admin
+  Port 22
+`
+
+func TestExportAnsibleINI(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(ansibleExportConfigTest))
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, config.ExportAnsible(buf, InventoryINI))
+	assert.Equal(t, `bastion ansible_host=bastion.example.com ansible_user=admin ansible_port=22
+
+[db]
+db1 ansible_host=10.0.1.1 ansible_user=admin ansible_port=2200 ansible_ssh_private_key_file=~/.ssh/db
+
+[prod]
+web1 ansible_host=10.0.0.1 ansible_user=deploy ansible_port=22 ansible_ssh_common_args='-J bastion'
+db1 ansible_host=10.0.1.1 ansible_user=admin ansible_port=2200 ansible_ssh_private_key_file=~/.ssh/db
+
+[web]
+web1 ansible_host=10.0.0.1 ansible_user=deploy ansible_port=22 ansible_ssh_common_args='-J bastion'
+`, buf.String())
+
+	// importing the inventory gives back the resolved hosts
+	imported, err := ImportAnsible(buf, InventoryINI)
+	assert.NoError(t, err)
+	for _, name := range []string{"web1", "bastion", "db1"} {
+		for _, keyword := range []string{HostNameKeyword, UserKeyword, PortKeyword, IdentityFileKeyword} {
+			want, got := config.Resolve(name).GetParam(keyword), imported.Resolve(name).GetParam(keyword)
+			if want == nil {
+				assert.Nil(t, got, name+" "+keyword)
+				continue
+			}
+			assert.Equal(t, want.Value(), got.Value(), name+" "+keyword)
+		}
+	}
+	assert.Equal(t, "bastion", imported.Resolve("web1").GetParam(ProxyJumpKeyword).Value())
+	assert.Nil(t, imported.Resolve("bastion").GetParam(ProxyJumpKeyword))
+}
+
+func TestExportAnsibleYAML(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(ansibleExportConfigTest))
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, config.ExportAnsible(buf, InventoryYAML))
+	assert.Equal(t, `all:
+  hosts:
+    bastion:
+      ansible_host: bastion.example.com
+      ansible_user: admin
+      ansible_port: 22
+  children:
+    db:
+      hosts:
+        db1:
+          ansible_host: 10.0.1.1
+          ansible_user: admin
+          ansible_port: 2200
+          ansible_ssh_private_key_file: ~/.ssh/db
+    prod:
+      hosts:
+        web1:
+          ansible_host: 10.0.0.1
+          ansible_user: deploy
+          ansible_port: 22
+          ansible_ssh_common_args: -J bastion
+        db1:
+          ansible_host: 10.0.1.1
+          ansible_user: admin
+          ansible_port: 2200
+          ansible_ssh_private_key_file: ~/.ssh/db
+    web:
+      hosts:
+        web1:
+          ansible_host: 10.0.0.1
+          ansible_user: deploy
+          ansible_port: 22
+          ansible_ssh_common_args: -J bastion
+`, buf.String())
+
+	imported, err := ImportAnsible(buf, InventoryYAML)
+	assert.NoError(t, err)
+	assert.Equal(t, "# groups: db prod", imported.GetHost("db1").Comments[0])
+	assert.Equal(t, "2200", imported.GetHost("db1").GetParam(PortKeyword).Value())
+
+	assert.ErrorIs(t, config.ExportAnsible(buf, InventoryCSV), ErrUnknownFormat)
+}
+
+func TestExportAnsibleHostNameTokens(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host web
+  HostName %h.example.com
+
+Host db
+  HostName db%%1.example.com
+`))
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, config.ExportAnsible(buf, InventoryINI))
+	assert.Equal(t, `web ansible_host=web.example.com
+db ansible_host=db%1.example.com
+`, buf.String())
+}
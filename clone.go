@@ -0,0 +1,206 @@
+package sshconfig
+
+import (
+	"io"
+	"slices"
+)
+
+// Clone returns a deep copy of the config, sharing no hosts, parameters
+// or slices with it
+func (config *Config) Clone() *Config {
+	dup, _, _ := config.duplicate()
+	return dup
+}
+
+// EqualOptions relaxes the comparison made by EqualWithOptions
+type EqualOptions struct {
+	// IgnoreComments ignores comments, including nodes that only hold
+	// comments
+	IgnoreComments bool
+	// IgnoreFormatting ignores the case of keywords and treats deprecated
+	// keywords as the keyword that replaced them
+	IgnoreFormatting bool
+}
+
+// Equal reports whether two configs hold the same blocks, parameters
+// and comments in the same order
+// Positions and the source the configs were parsed from are ignored
+func (config *Config) Equal(other *Config) bool {
+	return config.EqualWithOptions(other, EqualOptions{})
+}
+
+// EqualWithOptions reports whether two configs are equal, ignoring the
+// differences selected by opts
+func (config *Config) EqualWithOptions(other *Config, opts EqualOptions) bool {
+
+	if len(config.Hosts) != len(other.Hosts) {
+		return false
+	}
+	if !paramListsEqual(config.Globals, other.Globals, opts) {
+		return false
+	}
+
+	for i, host := range config.Hosts {
+		o := other.Hosts[i]
+		if !slices.Equal(host.Hostnames, o.Hostnames) || !slices.Equal(host.Criteria, o.Criteria) {
+			return false
+		}
+		if !opts.IgnoreComments && !slices.Equal(host.Comments, o.Comments) {
+			return false
+		}
+		if !paramListsEqual(host.Params, o.Params, opts) {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+// SemanticallyEqual reports whether ssh would read two configs the same
+// way: comments and formatting are ignored, and so are repeated
+// occurrences of a keyword within a block that never take effect
+// because an earlier occurrence wins
+func (config *Config) SemanticallyEqual(other *Config) bool {
+	return effective(config).EqualWithOptions(effective(other), EqualOptions{
+		IgnoreComments:   true,
+		IgnoreFormatting: true,
+	})
+}
+
+// effective returns a copy of the config without the parameters that
+// are shadowed by an earlier occurrence in the same block
+func effective(config *Config) *Config {
+
+	dedupe := func(params []*Param) []*Param {
+		var kept []*Param
+		seen := map[string]bool{}
+		for _, param := range params {
+			key := canonicalKeyword(param.Keyword)
+			if param.Keyword != "" && seen[key] && !isRepeatable(param.Keyword) {
+				continue
+			}
+			seen[key] = true
+			kept = append(kept, param)
+		}
+		return kept
+	}
+
+	dup := &Config{Globals: dedupe(config.Globals)}
+	for _, host := range config.Hosts {
+		dup.Hosts = append(dup.Hosts, &Host{
+			Hostnames: host.Hostnames,
+			Criteria:  host.Criteria,
+			Params:    dedupe(host.Params),
+		})
+	}
+
+	return dup
+
+}
+
+func paramListsEqual(a, b []*Param, opts EqualOptions) bool {
+
+	if opts.IgnoreComments {
+		a, b = withoutCommentNodes(a), withoutCommentNodes(b)
+	}
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		keywordA, keywordB := a[i].Keyword, b[i].Keyword
+		if opts.IgnoreFormatting {
+			keywordA, keywordB = canonicalKeyword(keywordA), canonicalKeyword(keywordB)
+		}
+		if keywordA != keywordB || !slices.Equal(a[i].Args, b[i].Args) {
+			return false
+		}
+		if !opts.IgnoreComments && !slices.Equal(a[i].Comments, b[i].Comments) {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+func withoutCommentNodes(params []*Param) []*Param {
+	var kept []*Param
+	for _, param := range params {
+		if param.Keyword != "" {
+			kept = append(kept, param)
+		}
+	}
+	return kept
+}
+
+// Snapshot is an immutable view of a config, which any number of
+// goroutines can read while a writer prepares the next version
+// Sharing a snapshot copies nothing: the config is copied when a writer
+// calls Edit, so readers are never affected by edits, and NewSnapshot
+// publishes the edited copy without copying it again
+type Snapshot struct {
+	config *Config
+}
+
+// Snapshot returns an immutable copy of the config
+func (config *Config) Snapshot() *Snapshot {
+	return &Snapshot{config: config.Clone()}
+}
+
+// NewSnapshot wraps config in a snapshot without copying it
+// The caller hands config over and must not change it afterwards
+func NewSnapshot(config *Config) *Snapshot {
+	return &Snapshot{config: config}
+}
+
+// Edit returns a copy of the snapshot's config that can be changed
+// freely, typically to build the next snapshot with NewSnapshot
+func (snapshot *Snapshot) Edit() *Config {
+	return snapshot.config.Clone()
+}
+
+// Resolve returns the parameters ssh would use for hostname, see Config.Resolve
+func (snapshot *Snapshot) Resolve(hostname string) *Host {
+	return snapshot.config.Resolve(hostname)
+}
+
+// Query returns copies of the nodes selected by expr, see Config.Query
+func (snapshot *Snapshot) Query(expr string) ([]QueryResult, error) {
+
+	results, err := snapshot.config.Query(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		if result.Host != nil {
+			results[i].Host = result.Host.Clone()
+		}
+		if result.Param != nil {
+			results[i].Param = result.Param.Clone()
+		}
+	}
+
+	return results, nil
+
+}
+
+// Lint checks the snapshot's config, see Config.Lint
+// The findings refer to a copy of the config, so they cannot be used
+// to edit the snapshot, and Config.Fix returns ErrFindingNotInConfig
+// for them
+func (snapshot *Snapshot) Lint() []Finding {
+	return snapshot.Edit().Lint()
+}
+
+// Equal reports whether two snapshots hold equal configs, see Config.Equal
+func (snapshot *Snapshot) Equal(other *Snapshot) bool {
+	return snapshot.config.Equal(other.config)
+}
+
+// WriteTo writes the snapshot's config, see Config.WriteTo
+func (snapshot *Snapshot) WriteTo(w io.Writer) (int64, error) {
+	return snapshot.config.WriteTo(w)
+}
@@ -0,0 +1,89 @@
+package sshconfig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var cloneConfigTest = `VisualHostKey yes
+
+# dev box
+Host dev
+  User ubuntu
+  IdentityFile ~/.ssh/dev
+`
+
+func TestClone(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(cloneConfigTest))
+	assert.NoError(t, err)
+
+	dup := config.Clone()
+	assert.True(t, config.Equal(dup))
+
+	dup.GetParam(VisualHostKeyKeyword).Args[0] = "no"
+	dup.Hosts[0].Hostnames[0] = "prod"
+	dup.Hosts[0].Params[0].Comments = append(dup.Hosts[0].Params[0].Comments, "# changed")
+
+	assert.Equal(t, "yes", config.GetParam(VisualHostKeyKeyword).Value())
+	assert.Equal(t, []string{"dev"}, config.Hosts[0].Hostnames)
+	assert.Empty(t, config.Hosts[0].Params[0].Comments)
+	assert.False(t, config.Equal(dup))
+}
+
+func TestEqual(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(cloneConfigTest))
+	assert.NoError(t, err)
+
+	reformatted, err := Parse(strings.NewReader(`visualhostkey yes
+host dev
+    user ubuntu
+    identityfile ~/.ssh/dev
+    User root
+`))
+	assert.NoError(t, err)
+
+	assert.False(t, config.Equal(reformatted))
+	assert.False(t, config.EqualWithOptions(reformatted, EqualOptions{IgnoreComments: true, IgnoreFormatting: true}))
+	assert.True(t, config.SemanticallyEqual(reformatted))
+
+	reformatted.Hosts[0].Params = reformatted.Hosts[0].Params[:2]
+	assert.False(t, config.EqualWithOptions(reformatted, EqualOptions{IgnoreFormatting: true}))
+	assert.False(t, config.EqualWithOptions(reformatted, EqualOptions{IgnoreComments: true}))
+	assert.True(t, config.EqualWithOptions(reformatted, EqualOptions{IgnoreComments: true, IgnoreFormatting: true}))
+
+	reformatted.Hosts[0].Params[1].Args = []string{"~/.ssh/other"}
+	assert.False(t, config.SemanticallyEqual(reformatted))
+}
+
+func TestSnapshot(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(cloneConfigTest))
+	assert.NoError(t, err)
+
+	snapshot := config.Snapshot()
+	config.Hosts[0].SetParam(UserKeyword, "root")
+	assert.Equal(t, "ubuntu", snapshot.Resolve("dev").GetParam(UserKeyword).Value())
+
+	results, err := snapshot.Query("host[dev].User")
+	assert.NoError(t, err)
+	results[0].Param.Args = []string{"changed"}
+	assert.Equal(t, "ubuntu", snapshot.Resolve("dev").GetParam(UserKeyword).Value())
+
+	next := snapshot.Edit()
+	next.Hosts[0].SetParam(UserKeyword, "deploy")
+	published := NewSnapshot(next)
+	assert.Equal(t, "ubuntu", snapshot.Resolve("dev").GetParam(UserKeyword).Value())
+	assert.Equal(t, "deploy", published.Resolve("dev").GetParam(UserKeyword).Value())
+	assert.False(t, snapshot.Equal(published))
+
+	before, after := &bytes.Buffer{}, &bytes.Buffer{}
+	snapshot.WriteTo(before)
+	published.WriteTo(after)
+	assert.Contains(t, before.String(), "User ubuntu")
+	assert.Contains(t, after.String(), "User deploy")
+}
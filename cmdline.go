@@ -0,0 +1,479 @@
+package sshconfig
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedOption is returned for ssh options that have no
+	// ssh_config equivalent, such as -F or -W
+	ErrUnsupportedOption = errors.New("sshconfig: unsupported ssh option")
+	// ErrNoDestination is returned when there is no host to connect to
+	ErrNoDestination = errors.New("sshconfig: no destination")
+)
+
+// commandLineFlag is the param set by an ssh option without an argument
+type commandLineFlag struct {
+	keyword, value string
+}
+
+// commandLineFlags maps the ssh options without an argument to the
+// params they set
+// Repeated options such as -vv have their own entry
+var commandLineFlags = map[string]commandLineFlag{
+	"-4":   {AddressFamilyKeyword, "inet"},
+	"-6":   {AddressFamilyKeyword, "inet6"},
+	"-A":   {ForwardAgentKeyword, "yes"},
+	"-a":   {ForwardAgentKeyword, "no"},
+	"-C":   {CompressionKeyword, "yes"},
+	"-f":   {ForkAfterAuthenticationKeyword, "yes"},
+	"-g":   {GatewayPortsKeyword, "yes"},
+	"-k":   {GSSAPIDelegateCredentialsKeyword, "no"},
+	"-M":   {ControlMasterKeyword, "yes"},
+	"-MM":  {ControlMasterKeyword, "ask"},
+	"-N":   {SessionTypeKeyword, "none"},
+	"-n":   {StdinNullKeyword, "yes"},
+	"-q":   {LogLevelKeyword, "QUIET"},
+	"-s":   {SessionTypeKeyword, "subsystem"},
+	"-T":   {RequestTTYKeyword, "no"},
+	"-t":   {RequestTTYKeyword, "yes"},
+	"-tt":  {RequestTTYKeyword, "force"},
+	"-v":   {LogLevelKeyword, "DEBUG1"},
+	"-vv":  {LogLevelKeyword, "DEBUG2"},
+	"-vvv": {LogLevelKeyword, "DEBUG3"},
+	"-X":   {ForwardX11Keyword, "yes"},
+	"-x":   {ForwardX11Keyword, "no"},
+}
+
+// commandLineOptions maps the ssh options that take an argument to the
+// keyword the argument is used for
+var commandLineOptions = map[byte]string{
+	'B': BindInterfaceKeyword,
+	'b': BindAddressKeyword,
+	'c': CiphersKeyword,
+	'D': DynamicForwardKeyword,
+	'e': EscapeCharKeyword,
+	'I': PKCS11ProviderKeyword,
+	'i': IdentityFileKeyword,
+	'J': ProxyJumpKeyword,
+	'L': LocalForwardKeyword,
+	'l': UserKeyword,
+	'm': MACsKeyword,
+	'p': PortKeyword,
+	'R': RemoteForwardKeyword,
+	'S': ControlPathKeyword,
+}
+
+// unsupportedOptions lists the ssh options that take an argument but
+// have no ssh_config equivalent
+const unsupportedOptions = "EFOPQW"
+
+// commandLine collects the params set by the options of an ssh command line
+type commandLine struct {
+	params  []*Param
+	counts  map[byte]int
+	counted map[byte]*Param
+}
+
+// ParseCommandLine returns the Host block for an ssh command line, such as
+//
+//	ssh -i ~/.ssh/key -p 2222 -J bastion -o ServerAliveInterval=30 deploy@web
+//
+// The block is named after the destination host, with User and Port
+// taken from user@host or ssh://user@host:port destinations, and the
+// remote command, if any, kept as RemoteCommand. Like ssh, the first
+// value given for a keyword wins, so -l and -p take precedence over the
+// destination. A leading "ssh" is skipped.
+// Options that only affect the ssh process, such as -F or -W, return
+// ErrUnsupportedOption
+func ParseCommandLine(argv []string) (*Host, error) {
+
+	if len(argv) > 0 && path.Base(argv[0]) == "ssh" {
+		argv = argv[1:]
+	}
+
+	cl := &commandLine{
+		counts:  map[byte]int{},
+		counted: map[byte]*Param{},
+	}
+
+	// like ssh, options are read until the first word after the destination
+	var destination string
+	var command []string
+	options := true
+	for i := 0; i < len(argv); i++ {
+		word := argv[i]
+		if options && word == "--" {
+			options = false
+			continue
+		}
+		if options && len(word) > 1 && word[0] == '-' {
+			consumed, err := cl.option(word, argv[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			i += consumed
+			continue
+		}
+		if destination == "" {
+			destination = word
+			continue
+		}
+		command = argv[i:]
+		break
+	}
+	if destination == "" {
+		return nil, ErrNoDestination
+	}
+
+	user, hostname, port, err := parseDestination(destination)
+	if err != nil {
+		return nil, err
+	}
+	if user != "" {
+		cl.params = append(cl.params, NewParam(UserKeyword, []string{user}, nil))
+	}
+	if port != "" {
+		cl.params = append(cl.params, NewParam(PortKeyword, []string{port}, nil))
+	}
+	if len(command) > 0 {
+		cl.params = append(cl.params, NewParam(RemoteCommandKeyword, strings.Fields(strings.Join(command, " ")), nil))
+	}
+
+	host := NewHost([]string{hostname}, nil)
+	seen := map[string]bool{}
+	for _, param := range cl.params {
+		info, _ := LookupKeyword(param.Keyword)
+		if len(param.Args) == 0 {
+			return nil, fmt.Errorf("%w: %s needs a value", ErrInvalidValue, info.Name)
+		}
+		if err := checkArgs(info, param.Args); err != nil {
+			return nil, err
+		}
+		key := canonicalKeyword(param.Keyword)
+		if seen[key] && !isRepeatable(param.Keyword) {
+			continue
+		}
+		seen[key] = true
+		host.AddParam(param)
+	}
+
+	return host, nil
+
+}
+
+// option reads the options in word, which may combine several flags
+// or include the argument of the last one, and returns how many of the
+// following words it used as arguments
+func (cl *commandLine) option(word string, rest []string) (int, error) {
+
+	for i := 1; i < len(word); i++ {
+		flag := word[i]
+
+		keyword, ok := commandLineOptions[flag]
+		if !ok && flag != 'o' && flag != 'w' && !strings.ContainsRune(unsupportedOptions, rune(flag)) {
+			if err := cl.flag(flag); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		// the argument is the rest of the word or the next word
+		value, consumed := word[i+1:], 0
+		if value == "" {
+			if len(rest) == 0 {
+				return 0, fmt.Errorf("%w: -%c needs a value", ErrInvalidValue, flag)
+			}
+			value, consumed = rest[0], 1
+		}
+
+		var err error
+		switch {
+		case flag == 'o':
+			err = cl.config(value)
+		case flag == 'w':
+			cl.add(TunnelKeyword, "yes")
+			cl.add(TunnelDeviceKeyword, value)
+		case !ok:
+			err = fmt.Errorf("%w: -%c", ErrUnsupportedOption, flag)
+		case flag == 'L' || flag == 'R':
+			var args []string
+			if args, err = forwardArgs(flag, value); err == nil {
+				cl.add(keyword, args...)
+			}
+		default:
+			cl.add(keyword, value)
+		}
+		return consumed, err
+	}
+
+	return 0, nil
+
+}
+
+// flag applies an ssh option without an argument
+func (cl *commandLine) flag(flag byte) error {
+
+	switch flag {
+	case 'K':
+		cl.add(GSSAPIAuthenticationKeyword, "yes")
+		cl.add(GSSAPIDelegateCredentialsKeyword, "yes")
+		return nil
+	case 'Y':
+		cl.add(ForwardX11Keyword, "yes")
+		cl.add(ForwardX11TrustedKeyword, "yes")
+		return nil
+	}
+
+	cl.counts[flag]++
+	n := cl.counts[flag]
+	set, ok := commandLineFlags["-"+strings.Repeat(string(flag), n)]
+	switch {
+	case ok && n > 1:
+		// -vv raises the level set by the first -v
+		cl.counted[flag].Args = []string{set.value}
+	case ok:
+		cl.counted[flag] = cl.add(set.keyword, set.value)
+	case n == 1:
+		return fmt.Errorf("%w: -%c", ErrUnsupportedOption, flag)
+	}
+
+	return nil
+
+}
+
+// config applies an -o option, written as "Keyword=value" or "Keyword value"
+func (cl *commandLine) config(option string) error {
+
+	keyword, args := splitKeyword(option)
+
+	info, err := lookupOption(keyword)
+	if err != nil {
+		return err
+	}
+
+	cl.add(info.Name, strings.Fields(args)...)
+	return nil
+
+}
+
+// lookupOption returns the registry entry for a keyword given with -o
+// ssh only accepts Host, Match and Include in a config file, so they
+// return ErrUnsupportedOption, and unknown keywords ErrUnknownKeyword
+func lookupOption(keyword string) (KeywordInfo, error) {
+
+	for _, block := range []string{HostKeyword, MatchKeyword, IncludeKeyword} {
+		if strings.EqualFold(keyword, block) {
+			return KeywordInfo{}, fmt.Errorf("%w: -o %s", ErrUnsupportedOption, block)
+		}
+	}
+	info, ok := LookupKeyword(keyword)
+	if !ok {
+		return KeywordInfo{}, fmt.Errorf("%w: %s", ErrUnknownKeyword, keyword)
+	}
+
+	return info, nil
+
+}
+
+func (cl *commandLine) add(keyword string, args ...string) *Param {
+	param := NewParam(keyword, args, nil)
+	cl.params = append(cl.params, param)
+	return param
+}
+
+// parseDestination splits an ssh destination, [user@]host or
+// ssh://[user@]host[:port], into its parts
+func parseDestination(destination string) (user, hostname, port string, err error) {
+
+	uri := strings.HasPrefix(destination, "ssh://")
+	rest := strings.TrimPrefix(destination, "ssh://")
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		user, rest = rest[:i], rest[i+1:]
+	}
+	hostname = rest
+
+	// only URIs have a port, after an IPv6 address in brackets
+	invalid := hostname == ""
+	if uri && strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end < 0 {
+			return "", "", "", fmt.Errorf("%w: destination %s", ErrInvalidValue, destination)
+		}
+		hostname, rest = rest[1:end], rest[end+1:]
+		invalid = hostname == "" || (rest != "" && rest[0] != ':')
+		if rest != "" {
+			port = rest[1:]
+			invalid = invalid || port == ""
+		}
+	} else if i := strings.LastIndex(rest, ":"); uri && i >= 0 {
+		hostname, port = rest[:i], rest[i+1:]
+		invalid = hostname == "" || port == ""
+	}
+	if port != "" {
+		_, err := strconv.ParseUint(port, 10, 16)
+		invalid = invalid || err != nil
+	}
+	if invalid {
+		return "", "", "", fmt.Errorf("%w: destination %s", ErrInvalidValue, destination)
+	}
+
+	return user, hostname, port, nil
+
+}
+
+// forwardArgs converts the argument of -L or -R to the arguments of
+// LocalForward or RemoteForward
+// For example 8080:localhost:80 becomes 8080 localhost:80. IPv6
+// addresses are written in brackets, and -R with only a port, or an
+// address and a port, is a dynamic forward that keeps a single argument
+func forwardArgs(flag byte, spec string) ([]string, error) {
+
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				parts = append(parts, spec[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, spec[start:])
+
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("%w: -%c %s", ErrInvalidValue, flag, spec)
+		}
+	}
+
+	switch len(parts) {
+	case 4:
+		return []string{parts[0] + ":" + parts[1], parts[2] + ":" + parts[3]}, nil
+	case 3:
+		return []string{parts[0], parts[1] + ":" + parts[2]}, nil
+	case 2:
+		if _, err := strconv.Atoi(parts[1]); err == nil && flag == 'R' {
+			return []string{spec}, nil
+		}
+		return parts, nil
+	case 1:
+		if flag == 'R' {
+			return parts, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: -%c %s", ErrInvalidValue, flag, spec)
+
+}
+
+// CommandLine returns the shortest ssh command line that connects with
+// the params of the block, such as one returned by Resolve
+// The destination is user@ followed by the HostName, or the first
+// pattern of the block without one, and RemoteCommand follows it. Params
+// with a short option, such as -i or -A, use it and the rest are passed
+// with -o; params ssh refuses on the command line, such as Include,
+// are left out. Match blocks without a HostName return ErrNoDestination
+func (host *Host) CommandLine() ([]string, error) {
+
+	destination := concreteHostname(host)
+	var user string
+	var command []string
+
+	argv := []string{"ssh"}
+	for _, param := range host.Params {
+		if !isOptionParam(param) {
+			continue
+		}
+		switch canonicalKeyword(param.Keyword) {
+		case strings.ToLower(HostNameKeyword):
+			destination = expandHostName(param.Value(), concreteHostname(host))
+			continue
+		case strings.ToLower(UserKeyword):
+			user = param.Value()
+			continue
+		case strings.ToLower(RemoteCommandKeyword):
+			command = param.Args
+			continue
+		}
+		argv = append(argv, commandLineArgs(param)...)
+	}
+	if destination == "" {
+		return nil, ErrNoDestination
+	}
+	if user != "" {
+		destination = user + "@" + destination
+	}
+
+	argv = append(argv, destination)
+	return append(argv, command...), nil
+
+}
+
+// commandLineArgs returns the ssh options that set param
+func commandLineArgs(param *Param) []string {
+
+	if len(param.Args) == 1 {
+		for flag, set := range commandLineFlags {
+			if strings.EqualFold(set.keyword, param.Keyword) && strings.EqualFold(set.value, param.Args[0]) {
+				return []string{flag}
+			}
+		}
+	}
+	for flag, keyword := range commandLineOptions {
+		if !strings.EqualFold(keyword, param.Keyword) {
+			continue
+		}
+		// forwards are written as a single argument, like 8080:localhost:80
+		separator := " "
+		if flag == 'L' || flag == 'R' {
+			separator = ":"
+		}
+		return []string{"-" + string(flag), strings.Join(param.Args, separator)}
+	}
+
+	return optionArgs(param)
+
+}
+
+// OptionArgs returns the params of the block as -o options for ssh,
+// such as "-o" "Port=2222"
+// Params ssh refuses on the command line, such as Include, are left out
+func (host *Host) OptionArgs() []string {
+
+	var args []string
+	for _, param := range host.Params {
+		if isOptionParam(param) {
+			args = append(args, optionArgs(param)...)
+		}
+	}
+	return args
+
+}
+
+// isOptionParam reports whether param can be given to ssh on the
+// command line; comments, Include and unknown keywords cannot
+func isOptionParam(param *Param) bool {
+	if param.Keyword == "" || len(param.Args) == 0 {
+		return false
+	}
+	_, err := lookupOption(param.Keyword)
+	return err == nil
+}
+
+func optionArgs(param *Param) []string {
+	keyword := param.Keyword
+	if info, ok := LookupKeyword(keyword); ok {
+		keyword = info.Name
+	}
+	return []string{"-o", keyword + "=" + strings.Join(param.Args, " ")}
+}
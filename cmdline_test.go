@@ -0,0 +1,190 @@
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommandLine(t *testing.T) {
+
+	host, err := ParseCommandLine(strings.Fields("ssh -i key -p 2222 -J bastion -L 8080:localhost:80 -o ServerAliveInterval=30 user@host"))
+	assert.NoError(t, err)
+	assert.Equal(t, `
+Host host
+  IdentityFile key
+  Port 2222
+  ProxyJump bastion
+  LocalForward 8080 localhost:80
+  ServerAliveInterval 30
+  User user
+`, host.String())
+
+	argv, err := host.CommandLine()
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Fields("ssh -i key -p 2222 -J bastion -L 8080:localhost:80 -o ServerAliveInterval=30 user@host"), argv)
+}
+
+func TestParseCommandLineOptions(t *testing.T) {
+
+	host, err := ParseCommandLine([]string{
+		"/usr/bin/ssh", "-AvvC", "-ttl", "admin", "-oUser=other", "-o", "SendEnv LANG LC_*",
+		"-R", "1080", "-R", "[::1]:9000:db:5432", "-K", "web", "-N", "-p2200", "--", "ls", "-l /tmp",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `
+Host web
+  ForwardAgent yes
+  LogLevel DEBUG2
+  Compression yes
+  RequestTTY force
+  User admin
+  SendEnv LANG LC_*
+  RemoteForward 1080
+  RemoteForward [::1]:9000 db:5432
+  GSSAPIAuthentication yes
+  GSSAPIDelegateCredentials yes
+  SessionType none
+  Port 2200
+  RemoteCommand ls -l /tmp
+`, host.String())
+
+	argv, err := host.CommandLine()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ssh", "-A", "-vv", "-C", "-tt", "-o", "SendEnv=LANG LC_*", "-R", "1080", "-R", "[::1]:9000:db:5432",
+		"-o", "GSSAPIAuthentication=yes", "-o", "GSSAPIDelegateCredentials=yes", "-N", "-p", "2200",
+		"admin@web", "ls", "-l", "/tmp",
+	}, argv)
+
+	// the command line gives back the same block
+	reparsed, err := ParseCommandLine(argv)
+	assert.NoError(t, err)
+	again, err := reparsed.CommandLine()
+	assert.NoError(t, err)
+	assert.Equal(t, argv, again)
+}
+
+func TestParseCommandLineConfigSeparators(t *testing.T) {
+
+	host, err := ParseCommandLine([]string{"-o", "User = bob", "-o", "Port\t=2222", "-o", " Compression yes", "web"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, host.GetParam(UserKeyword).Args)
+	assert.Equal(t, []string{"2222"}, host.GetParam(PortKeyword).Args)
+	assert.Equal(t, []string{"yes"}, host.GetParam(CompressionKeyword).Args)
+
+	argv, err := host.CommandLine()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssh", "-p", "2222", "-C", "bob@web"}, argv)
+}
+
+func TestParseCommandLineDestinations(t *testing.T) {
+
+	for destination, want := range map[string][3]string{
+		"web":                        {"", "web", ""},
+		"me@corp@web":                {"me@corp", "web", ""},
+		"ssh://web:2222":             {"", "web", "2222"},
+		"ssh://deploy@[::1]:2222":    {"deploy", "::1", "2222"},
+		"ssh://deploy@web.example":   {"deploy", "web.example", ""},
+		"fe80::1":                    {"", "fe80::1", ""},
+		"ssh://deploy@[fe80::1%en0]": {"deploy", "fe80::1%en0", ""},
+	} {
+		user, hostname, port, err := parseDestination(destination)
+		assert.NoError(t, err, destination)
+		assert.Equal(t, want, [3]string{user, hostname, port}, destination)
+	}
+
+	for _, destination := range []string{"user@", "ssh://web:", "ssh://web:ssh", "ssh://[::1", "ssh://[::1]x"} {
+		_, _, _, err := parseDestination(destination)
+		assert.ErrorIs(t, err, ErrInvalidValue, destination)
+	}
+
+	host, err := ParseCommandLine([]string{"-l", "admin", "-p", "22", "ssh://deploy@web:2222"})
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", host.GetParam(UserKeyword).Value())
+	assert.Equal(t, "22", host.GetParam(PortKeyword).Value())
+}
+
+func TestParseCommandLineErrors(t *testing.T) {
+
+	for argv, want := range map[string]error{
+		"ssh":                    ErrNoDestination,
+		"ssh -A":                 ErrNoDestination,
+		"ssh -F config web":      ErrUnsupportedOption,
+		"ssh -W db:22 web":       ErrUnsupportedOption,
+		"ssh -G web":             ErrUnsupportedOption,
+		"ssh -o Host=db web":     ErrUnsupportedOption,
+		"ssh -o Usr=admin web":   ErrUnknownKeyword,
+		"ssh -o Port=ssh web":    ErrInvalidValue,
+		"ssh -o Compression web": ErrInvalidValue,
+		"ssh -L 8080 web":        ErrInvalidValue,
+		"ssh -L 8080::80 web":    ErrInvalidValue,
+		"ssh web -p":             ErrInvalidValue,
+	} {
+		_, err := ParseCommandLine(strings.Fields(argv))
+		assert.ErrorIs(t, err, want, argv)
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host web
+  HostName %h.example.com
+  User deploy
+  ForwardX11 yes
+  ForwardX11Trusted yes
+  ProxyCommand ssh -W %h:%p bastion
+  IdentityFile ~/.ssh/web
+  IdentityFile ~/.ssh/other
+
+Host *
+  ServerAliveInterval 30
+`))
+	assert.NoError(t, err)
+
+	resolved := config.Resolve("web")
+	argv, err := resolved.CommandLine()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ssh", "-X", "-o", "ForwardX11Trusted=yes", "-o", "ProxyCommand=ssh -W %h:%p bastion",
+		"-i", "~/.ssh/web", "-i", "~/.ssh/other", "-o", "ServerAliveInterval=30", "deploy@web.example.com",
+	}, argv)
+
+	assert.Equal(t, []string{
+		"-o", "HostName=%h.example.com", "-o", "User=deploy", "-o", "ForwardX11=yes", "-o", "ForwardX11Trusted=yes",
+		"-o", "ProxyCommand=ssh -W %h:%p bastion", "-o", "IdentityFile=~/.ssh/web", "-o", "IdentityFile=~/.ssh/other",
+		"-o", "ServerAliveInterval=30",
+	}, resolved.OptionArgs())
+
+	_, err = NewMatch([]string{"all"}, nil).CommandLine()
+	assert.ErrorIs(t, err, ErrNoDestination)
+}
+
+func TestCommandLineSkipsInclude(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Include ~/.ssh/other
+Port 2222
+
+Host web
+  User deploy
+`))
+	assert.NoError(t, err)
+
+	resolved := config.Resolve("web")
+	assert.Equal(t, "~/.ssh/other", resolved.GetParam(IncludeKeyword).Value())
+
+	argv, err := resolved.CommandLine()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssh", "-p", "2222", "deploy@web"}, argv)
+	assert.Equal(t, []string{"-o", "Port=2222", "-o", "User=deploy"}, resolved.OptionArgs())
+
+	// the command line parses back into the same params, without Include
+	host, err := ParseCommandLine(argv)
+	assert.NoError(t, err)
+	again, err := host.CommandLine()
+	assert.NoError(t, err)
+	assert.Equal(t, argv, again)
+}
@@ -0,0 +1,472 @@
+package sshconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeKind describes how a block or parameter differs between two configs
+type ChangeKind int
+
+// Kinds of change reported by Diff
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeRemoved
+	ChangeModified
+	ChangeMoved
+)
+
+func (kind ChangeKind) String() string {
+	switch kind {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	case ChangeMoved:
+		return "moved"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(kind))
+}
+
+func (kind ChangeKind) symbol() string {
+	switch kind {
+	case ChangeAdded:
+		return "+"
+	case ChangeRemoved:
+		return "-"
+	case ChangeMoved:
+		return ">"
+	}
+	return "~"
+}
+
+// ParamChange describes a parameter that differs between two configs
+// OldArgs is empty for added parameters and NewArgs for removed ones
+type ParamChange struct {
+	Kind    ChangeKind
+	Keyword string
+	OldArgs []string
+	NewArgs []string
+	// Occurrence counts, from 1, which parameter with the keyword the
+	// change refers to, in the old config for removed parameters and
+	// in the new config otherwise
+	Occurrence int
+}
+
+func (change ParamChange) String() string {
+	switch change.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s %s", change.Keyword, strings.Join(change.NewArgs, " "))
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s %s", change.Keyword, strings.Join(change.OldArgs, " "))
+	}
+	return fmt.Sprintf("~ %s %s -> %s", change.Keyword, strings.Join(change.OldArgs, " "), strings.Join(change.NewArgs, " "))
+}
+
+// BlockChange describes a Host or Match block that differs between two configs
+// Blocks are identified by their keyword and patterns. A moved block
+// may also carry parameter changes
+type BlockChange struct {
+	Kind     ChangeKind
+	Keyword  string
+	Patterns []string
+	// OldIndex and NewIndex are the positions of the block in each
+	// config's Hosts, or -1 when the block is missing from that config
+	OldIndex int
+	NewIndex int
+	// Occurrence counts, from 1, which block with the same first line
+	// this is, in the old config for removed blocks and in the new
+	// config otherwise
+	Occurrence int
+	// After is the first line of the block that precedes this one in
+	// the new config, empty when it comes first or was removed
+	After string
+	// AfterOccurrence counts which block with the first line After is
+	AfterOccurrence int
+	// ChangesFirstMatch is set on moved blocks whose new position
+	// changes which value ssh picks first for some host
+	ChangesFirstMatch bool
+	Params            []ParamChange
+}
+
+func (change BlockChange) String() string {
+
+	buf := &strings.Builder{}
+
+	fmt.Fprintf(buf, "%s %s %s", change.Kind.symbol(), change.Keyword, strings.Join(change.Patterns, " "))
+	if change.Kind == ChangeMoved {
+		fmt.Fprintf(buf, " (moved from %d to %d", change.OldIndex+1, change.NewIndex+1)
+		if change.ChangesFirstMatch {
+			buf.WriteString(", changes first-match order")
+		}
+		buf.WriteString(")")
+	}
+	buf.WriteString("\n")
+
+	for _, param := range change.Params {
+		fmt.Fprintf(buf, "    %s\n", param)
+	}
+
+	return buf.String()
+
+}
+
+// ConfigDiff is the semantic difference between two configs
+// Comments and formatting are ignored
+type ConfigDiff struct {
+	Globals []ParamChange
+	Blocks  []BlockChange
+}
+
+// Empty reports whether the two configs are semantically the same
+func (diff *ConfigDiff) Empty() bool {
+	return len(diff.Globals) == 0 && len(diff.Blocks) == 0
+}
+
+func (diff *ConfigDiff) String() string {
+
+	buf := &strings.Builder{}
+
+	if len(diff.Globals) > 0 {
+		buf.WriteString("~ global configuration\n")
+		for _, param := range diff.Globals {
+			fmt.Fprintf(buf, "    %s\n", param)
+		}
+	}
+
+	for _, block := range diff.Blocks {
+		buf.WriteString(block.String())
+	}
+
+	return buf.String()
+
+}
+
+// Diff compares two configs block by block
+// Host and Match blocks are paired up by their patterns, and
+// parameters within a block by keyword
+func Diff(a, b *Config) *ConfigDiff {
+
+	diff := &ConfigDiff{
+		Globals: diffParams(a.Globals, b.Globals),
+	}
+
+	pairs := pairBlocks(a.Hosts, b.Hosts)
+	moved := movedBlocks(pairs)
+	oldOccurrences, newOccurrences := blockOccurrences(a.Hosts), blockOccurrences(b.Hosts)
+
+	for _, pair := range pairs {
+
+		var host *Host
+		change := BlockChange{OldIndex: pair.old, NewIndex: pair.new}
+		if pair.new > 0 {
+			change.After = b.Hosts[pair.new-1].header()
+			change.AfterOccurrence = newOccurrences[pair.new-1]
+		}
+		if pair.new >= 0 {
+			change.Occurrence = newOccurrences[pair.new]
+		} else {
+			change.Occurrence = oldOccurrences[pair.old]
+		}
+
+		switch {
+		case pair.old < 0:
+			host = b.Hosts[pair.new]
+			change.Kind = ChangeAdded
+			change.Params = diffParams(nil, host.Params)
+		case pair.new < 0:
+			host = a.Hosts[pair.old]
+			change.Kind = ChangeRemoved
+			change.Params = diffParams(host.Params, nil)
+		default:
+			host = b.Hosts[pair.new]
+			change.Params = diffParams(a.Hosts[pair.old].Params, host.Params)
+			change.Kind = ChangeModified
+			if moved[pair.new] {
+				change.Kind = ChangeMoved
+				change.ChangesFirstMatch = changesFirstMatch(a.Hosts, b.Hosts, pairs, pair)
+			} else if len(change.Params) == 0 {
+				continue
+			}
+		}
+
+		change.Keyword = host.keyword()
+		change.Patterns = host.patterns()
+		diff.Blocks = append(diff.Blocks, change)
+
+	}
+
+	return diff
+
+}
+
+// occurrences numbers each key from 1 among the keys equal to it
+func occurrences(keys []string) []int {
+	numbers := make([]int, len(keys))
+	counts := map[string]int{}
+	for i, key := range keys {
+		counts[key]++
+		numbers[i] = counts[key]
+	}
+	return numbers
+}
+
+// blockOccurrences numbers each block among the blocks with the same first line
+func blockOccurrences(hosts []*Host) []int {
+	keys := make([]string, len(hosts))
+	for i, host := range hosts {
+		keys[i] = blockKey(host)
+	}
+	return occurrences(keys)
+}
+
+// keywordOccurrences numbers each parameter among those with the same keyword
+func keywordOccurrences(params []*Param) []int {
+	keys := make([]string, len(params))
+	for i, param := range params {
+		keys[i] = canonicalKeyword(param.Keyword)
+	}
+	return occurrences(keys)
+}
+
+// blockPair links a block in the old config to the same block in the
+// new config; either index is -1 when the block only exists on one side
+type blockPair struct {
+	old, new int
+}
+
+func blockKey(host *Host) string {
+	return strings.ToLower(host.header())
+}
+
+// blockKeys returns a key for each block that identifies it across
+// configs, numbering blocks that share the same patterns
+func blockKeys(hosts []*Host) []string {
+	keys := make([]string, len(hosts))
+	counts := map[string]int{}
+	for i, host := range hosts {
+		key := blockKey(host)
+		counts[key]++
+		keys[i] = fmt.Sprintf("%s#%d", key, counts[key])
+	}
+	return keys
+}
+
+// pairBlocks pairs blocks with the same key in the order they appear
+// The result lists the new config's blocks in order, with removed
+// blocks placed after the block that preceded them
+func pairBlocks(a, b []*Host) []blockPair {
+
+	oldByKey := map[string]int{}
+	for i, key := range blockKeys(a) {
+		oldByKey[key] = i
+	}
+
+	newFor := make([]int, len(a))
+	for i := range newFor {
+		newFor[i] = -1
+	}
+
+	var pairs []blockPair
+	for j, key := range blockKeys(b) {
+		if i, ok := oldByKey[key]; ok {
+			newFor[i] = j
+			pairs = append(pairs, blockPair{old: i, new: j})
+			continue
+		}
+		pairs = append(pairs, blockPair{old: -1, new: j})
+	}
+
+	// slot each removed block in after the pair holding its predecessor
+	for i := len(a) - 1; i >= 0; i-- {
+		if newFor[i] >= 0 {
+			continue
+		}
+		at := 0
+		for k := i - 1; k >= 0; k-- {
+			if newFor[k] >= 0 {
+				for p, pair := range pairs {
+					if pair.old == k {
+						at = p + 1
+					}
+				}
+				break
+			}
+		}
+		pairs = append(pairs[:at], append([]blockPair{{old: i, new: -1}}, pairs[at:]...)...)
+	}
+
+	return pairs
+
+}
+
+// movedBlocks returns the new indexes of blocks present in both configs
+// that are not part of the longest run of blocks kept in order
+func movedBlocks(pairs []blockPair) map[int]bool {
+
+	var common []blockPair
+	for _, pair := range pairs {
+		if pair.old >= 0 && pair.new >= 0 {
+			common = append(common, pair)
+		}
+	}
+
+	// longest increasing subsequence of old indexes, in new order
+	length := make([]int, len(common))
+	prev := make([]int, len(common))
+	best := -1
+	for i := range common {
+		length[i], prev[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if common[j].old < common[i].old && length[j]+1 > length[i] {
+				length[i], prev[i] = length[j]+1, j
+			}
+		}
+		if best < 0 || length[i] > length[best] {
+			best = i
+		}
+	}
+
+	inOrder := map[int]bool{}
+	for i := best; i >= 0; i = prev[i] {
+		inOrder[common[i].new] = true
+	}
+
+	moved := map[int]bool{}
+	for _, pair := range common {
+		if !inOrder[pair.new] {
+			moved[pair.new] = true
+		}
+	}
+
+	return moved
+
+}
+
+// changesFirstMatch reports whether moving a block swapped it with
+// another block that can match the same host and sets the same keyword
+func changesFirstMatch(a, b []*Host, pairs []blockPair, moved blockPair) bool {
+
+	for _, other := range pairs {
+		if other.old < 0 || other.new < 0 || other == moved {
+			continue
+		}
+		if (other.old < moved.old) == (other.new < moved.new) {
+			continue
+		}
+		if mayOverlap(b[moved.new], b[other.new]) && sharesKeyword(a[moved.old], a[other.old]) {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// mayOverlap reports whether two blocks could both apply to one host
+// Match blocks and pairs of wildcard patterns are assumed to overlap
+func mayOverlap(x, y *Host) bool {
+
+	if x.IsMatch() || y.IsMatch() {
+		return true
+	}
+
+	for _, px := range x.Hostnames {
+		for _, py := range y.Hostnames {
+			if strings.HasPrefix(px, "!") || strings.HasPrefix(py, "!") {
+				continue
+			}
+			if isWildcardPattern(px) && isWildcardPattern(py) {
+				return true
+			}
+			if matchPattern(strings.ToLower(px), strings.ToLower(py)) || matchPattern(strings.ToLower(py), strings.ToLower(px)) {
+				return true
+			}
+		}
+	}
+
+	return false
+
+}
+
+func sharesKeyword(x, y *Host) bool {
+	keywords := map[string]bool{}
+	for _, param := range x.Params {
+		keywords[canonicalKeyword(param.Keyword)] = true
+	}
+	for _, param := range y.Params {
+		if keywords[canonicalKeyword(param.Keyword)] {
+			return true
+		}
+	}
+	return false
+}
+
+// paramKeys returns a key for each parameter that identifies it across
+// configs: single-valued keywords are keyed by occurrence, while repeatable
+// keywords such as IdentityFile are keyed by value. Parameters holding
+// only comments get an empty key
+func paramKeys(params []*Param) []string {
+
+	keys := make([]string, len(params))
+	counts := map[string]int{}
+
+	for i, param := range params {
+		if param.Keyword == "" {
+			continue
+		}
+		key := canonicalKeyword(param.Keyword)
+		if isRepeatable(param.Keyword) {
+			key += " " + strings.Join(param.Args, " ")
+		}
+		counts[key]++
+		keys[i] = fmt.Sprintf("%s#%d", key, counts[key])
+	}
+
+	return keys
+
+}
+
+// diffParams compares two parameter lists, pairing them by paramKeys
+func diffParams(a, b []*Param) []ParamChange {
+
+	var changes []ParamChange
+
+	oldKeys, newKeys := paramKeys(a), paramKeys(b)
+	oldOccurrences, newOccurrences := keywordOccurrences(a), keywordOccurrences(b)
+	oldByKey, newByKey := map[string]*Param{}, map[string]*Param{}
+	for i, key := range oldKeys {
+		oldByKey[key] = a[i]
+	}
+	for i, key := range newKeys {
+		newByKey[key] = b[i]
+	}
+
+	for i, key := range oldKeys {
+		if _, ok := newByKey[key]; !ok && key != "" {
+			changes = append(changes, ParamChange{Kind: ChangeRemoved, Keyword: a[i].Keyword, OldArgs: a[i].Args, Occurrence: oldOccurrences[i]})
+		}
+	}
+
+	for i, key := range newKeys {
+		if key == "" {
+			continue
+		}
+		old, ok := oldByKey[key]
+		switch {
+		case !ok:
+			changes = append(changes, ParamChange{Kind: ChangeAdded, Keyword: b[i].Keyword, NewArgs: b[i].Args, Occurrence: newOccurrences[i]})
+		case !argsEqual(old.Args, b[i].Args):
+			changes = append(changes, ParamChange{Kind: ChangeModified, Keyword: b[i].Keyword, OldArgs: old.Args, NewArgs: b[i].Args, Occurrence: newOccurrences[i]})
+		}
+	}
+
+	return changes
+
+}
+
+func argsEqual(a, b []string) bool {
+	return strings.Join(a, " ") == strings.Join(b, " ")
+}
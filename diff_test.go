@@ -0,0 +1,124 @@
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+
+	a, err := Parse(strings.NewReader(`
+VisualHostKey yes
+
+Host dev
+  HostName 127.0.0.1
+  User ubuntu
+  IdentityFile ~/.ssh/one
+  Port 22
+
+Host old
+  User root
+
+Match host *.internal
+  ProxyJump bastion
+`))
+	assert.NoError(t, err)
+
+	b, err := Parse(strings.NewReader(`
+VisualHostKey no
+
+# comments are ignored
+Host dev
+  hostname 127.0.0.1
+  User admin
+  IdentityFile ~/.ssh/one
+  IdentityFile ~/.ssh/two
+
+Match host *.internal
+  ProxyJump bastion
+
+Host new
+  User git
+`))
+	assert.NoError(t, err)
+
+	diff := Diff(a, b)
+
+	expected := `~ global configuration
+    ~ VisualHostKey yes -> no
+~ Host dev
+    - Port 22
+    ~ User ubuntu -> admin
+    + IdentityFile ~/.ssh/two
+- Host old
+    - User root
++ Host new
+    + User git
+`
+
+	assert.Equal(t, expected, diff.String())
+	assert.Equal(t, ChangeRemoved, diff.Blocks[1].Kind)
+	assert.Equal(t, 1, diff.Blocks[1].OldIndex)
+	assert.Equal(t, -1, diff.Blocks[1].NewIndex)
+	assert.Equal(t, []string{"new"}, diff.Blocks[2].Patterns)
+}
+
+func TestDiff_Reordered(t *testing.T) {
+
+	a, err := Parse(strings.NewReader(`
+Host *
+  User root
+
+Host dev
+  User ubuntu
+
+Host db
+  Port 5432
+
+Host web
+  Port 443
+`))
+	assert.NoError(t, err)
+
+	b, err := Parse(strings.NewReader(`
+Host dev
+  User ubuntu
+
+Host web
+  Port 443
+
+Host db
+  Port 5432
+
+Host *
+  User root
+`))
+	assert.NoError(t, err)
+
+	diff := Diff(a, b)
+
+	assert.Len(t, diff.Blocks, 2)
+	assert.Equal(t, ChangeMoved, diff.Blocks[0].Kind)
+	assert.Equal(t, []string{"db"}, diff.Blocks[0].Patterns)
+	assert.False(t, diff.Blocks[0].ChangesFirstMatch)
+	assert.Equal(t, ChangeMoved, diff.Blocks[1].Kind)
+	assert.Equal(t, []string{"*"}, diff.Blocks[1].Patterns)
+	assert.True(t, diff.Blocks[1].ChangesFirstMatch)
+	assert.Equal(t, "> Host * (moved from 1 to 4, changes first-match order)\n", diff.Blocks[1].String())
+}
+
+func TestDiff_Equal(t *testing.T) {
+
+	a, err := Parse(strings.NewReader(sshConfigTest))
+	assert.NoError(t, err)
+
+	formatted, err := Format([]byte(sshConfigTest), FormatOptions{Indent: 4})
+	assert.NoError(t, err)
+
+	b, err := Parse(strings.NewReader(string(formatted)))
+	assert.NoError(t, err)
+
+	assert.True(t, Diff(a, b).Empty())
+}
@@ -0,0 +1,253 @@
+package sshconfig
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configDocument and friends are the encoded form of the AST, see Config.MarshalJSON
+type (
+	configDocument struct {
+		Globals []paramDocument `json:"globals,omitempty" yaml:"globals,omitempty"`
+		Blocks  []hostDocument  `json:"blocks,omitempty" yaml:"blocks,omitempty"`
+	}
+	hostDocument struct {
+		Type     string          `json:"type" yaml:"type"`
+		Patterns []string        `json:"patterns,omitempty" yaml:"patterns,omitempty"`
+		Criteria []string        `json:"criteria,omitempty" yaml:"criteria,omitempty"`
+		Comments []string        `json:"comments,omitempty" yaml:"comments,omitempty"`
+		File     string          `json:"file,omitempty" yaml:"file,omitempty"`
+		Line     int             `json:"line,omitempty" yaml:"line,omitempty"`
+		Params   []paramDocument `json:"params,omitempty" yaml:"params,omitempty"`
+	}
+	paramDocument struct {
+		Keyword  string   `json:"keyword" yaml:"keyword"`
+		Args     []string `json:"args,omitempty" yaml:"args,omitempty"`
+		Comments []string `json:"comments,omitempty" yaml:"comments,omitempty"`
+		File     string   `json:"file,omitempty" yaml:"file,omitempty"`
+		Line     int      `json:"line,omitempty" yaml:"line,omitempty"`
+	}
+)
+
+// Block types used by the encoded schema
+const (
+	blockTypeHost  = "host"
+	blockTypeMatch = "match"
+)
+
+func (config *Config) document() configDocument {
+	doc := configDocument{}
+	for _, param := range config.Globals {
+		doc.Globals = append(doc.Globals, param.document())
+	}
+	for _, host := range config.Hosts {
+		doc.Blocks = append(doc.Blocks, host.document())
+	}
+	return doc
+}
+
+func (host *Host) document() hostDocument {
+	doc := hostDocument{
+		Type:     blockTypeHost,
+		Patterns: host.Hostnames,
+		Comments: host.Comments,
+		File:     host.Pos.File,
+		Line:     host.Pos.Line,
+	}
+	if host.IsMatch() {
+		doc.Type, doc.Patterns, doc.Criteria = blockTypeMatch, nil, host.Criteria
+	}
+	for _, param := range host.Params {
+		doc.Params = append(doc.Params, param.document())
+	}
+	return doc
+}
+
+func (param *Param) document() paramDocument {
+	return paramDocument{
+		Keyword:  param.Keyword,
+		Args:     param.Args,
+		Comments: param.Comments,
+		File:     param.Pos.File,
+		Line:     param.Pos.Line,
+	}
+}
+
+func (doc configDocument) config() (*Config, error) {
+	config := &Config{}
+	for _, param := range doc.Globals {
+		config.Globals = append(config.Globals, param.param())
+	}
+	for i, block := range doc.Blocks {
+		host, err := block.host()
+		if err != nil {
+			return nil, fmt.Errorf("block %d: %w", i, err)
+		}
+		config.Hosts = append(config.Hosts, host)
+	}
+	return config, nil
+}
+
+func (doc hostDocument) host() (*Host, error) {
+
+	var host *Host
+	switch doc.Type {
+	case blockTypeHost:
+		if len(doc.Patterns) == 0 || len(doc.Criteria) > 0 {
+			return nil, fmt.Errorf("host block needs patterns and no criteria")
+		}
+		host = NewHost(doc.Patterns, doc.Comments)
+	case blockTypeMatch:
+		if len(doc.Criteria) == 0 || len(doc.Patterns) > 0 {
+			return nil, fmt.Errorf("match block needs criteria and no patterns")
+		}
+		host = NewMatch(doc.Criteria, doc.Comments)
+	default:
+		return nil, fmt.Errorf("unknown block type %q", doc.Type)
+	}
+
+	host.Pos = Position{File: doc.File, Line: doc.Line}
+	for _, param := range doc.Params {
+		host.Params = append(host.Params, param.param())
+	}
+
+	return host, nil
+
+}
+
+func (doc paramDocument) param() *Param {
+	param := NewParam(doc.Keyword, doc.Args, doc.Comments)
+	param.Pos = Position{File: doc.File, Line: doc.Line}
+	return param
+}
+
+// MarshalJSON encodes the config with the following schema, shown as
+// YAML, where blocks keep their order and every field but keyword, type
+// and the patterns or criteria is optional:
+//
+//	globals:
+//	  - keyword: VisualHostKey
+//	    args: [yes]
+//	    comments: ["# show the key"]
+//	    line: 2
+//	blocks:
+//	  - type: host
+//	    patterns: [dev]
+//	    comments: ["# dev"]
+//	    line: 4
+//	    params:
+//	      - keyword: User
+//	        args: [ubuntu]
+//	        line: 5
+//	  - type: match
+//	    criteria: [host, "*.internal"]
+//
+// line is the source line the node was parsed from, and file the file
+// it was loaded from when the config spans several files. Config.Source
+// is not encoded.
+func (config *Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(config.document())
+}
+
+// UnmarshalJSON decodes a config encoded by MarshalJSON
+func (config *Config) UnmarshalJSON(data []byte) error {
+	var doc configDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	decoded, err := doc.config()
+	if err != nil {
+		return err
+	}
+	*config = *decoded
+	return nil
+}
+
+// MarshalYAML encodes the config with the schema of MarshalJSON
+func (config *Config) MarshalYAML() (interface{}, error) {
+	return config.document(), nil
+}
+
+// UnmarshalYAML decodes a config encoded by MarshalYAML
+func (config *Config) UnmarshalYAML(value *yaml.Node) error {
+	var doc configDocument
+	if err := value.Decode(&doc); err != nil {
+		return err
+	}
+	decoded, err := doc.config()
+	if err != nil {
+		return err
+	}
+	*config = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the block as one entry of the blocks list
+func (host *Host) MarshalJSON() ([]byte, error) {
+	return json.Marshal(host.document())
+}
+
+// UnmarshalJSON decodes a block encoded by MarshalJSON
+func (host *Host) UnmarshalJSON(data []byte) error {
+	var doc hostDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	decoded, err := doc.host()
+	if err != nil {
+		return err
+	}
+	*host = *decoded
+	return nil
+}
+
+// MarshalYAML encodes the block as one entry of the blocks list
+func (host *Host) MarshalYAML() (interface{}, error) {
+	return host.document(), nil
+}
+
+// UnmarshalYAML decodes a block encoded by MarshalYAML
+func (host *Host) UnmarshalYAML(value *yaml.Node) error {
+	var doc hostDocument
+	if err := value.Decode(&doc); err != nil {
+		return err
+	}
+	decoded, err := doc.host()
+	if err != nil {
+		return err
+	}
+	*host = *decoded
+	return nil
+}
+
+// MarshalJSON encodes the parameter as one entry of a params list
+func (param *Param) MarshalJSON() ([]byte, error) {
+	return json.Marshal(param.document())
+}
+
+// UnmarshalJSON decodes a parameter encoded by MarshalJSON
+func (param *Param) UnmarshalJSON(data []byte) error {
+	var doc paramDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	*param = *doc.param()
+	return nil
+}
+
+// MarshalYAML encodes the parameter as one entry of a params list
+func (param *Param) MarshalYAML() (interface{}, error) {
+	return param.document(), nil
+}
+
+// UnmarshalYAML decodes a parameter encoded by MarshalYAML
+func (param *Param) UnmarshalYAML(value *yaml.Node) error {
+	var doc paramDocument
+	if err := value.Decode(&doc); err != nil {
+		return err
+	}
+	*param = *doc.param()
+	return nil
+}
@@ -0,0 +1,73 @@
+package sshconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+var encodingConfigTest = `# show the key
+VisualHostKey yes
+
+# dev box
+Host dev
+  HostName 127.0.0.1
+  # login
+  User ubuntu
+
+Match host *.internal exec "test -f /tmp/vpn"
+  ProxyJump bastion
+`
+
+func TestEncodingRoundTrip(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(encodingConfigTest))
+	assert.NoError(t, err)
+
+	expected := &bytes.Buffer{}
+	config.WriteTo(expected)
+
+	jsonData, err := json.Marshal(config)
+	assert.NoError(t, err)
+	yamlData, err := yaml.Marshal(config)
+	assert.NoError(t, err)
+
+	fromJSON := &Config{}
+	assert.NoError(t, json.Unmarshal(jsonData, fromJSON))
+	fromYAML := &Config{}
+	assert.NoError(t, yaml.Unmarshal(yamlData, fromYAML))
+
+	for _, decoded := range []*Config{fromJSON, fromYAML} {
+		actual := &bytes.Buffer{}
+		decoded.WriteTo(actual)
+		assert.Equal(t, expected.String(), actual.String())
+		assert.Len(t, decoded.Hosts, 2)
+		assert.True(t, decoded.Hosts[1].IsMatch())
+		assert.Equal(t, config.Hosts[1].Criteria, decoded.Hosts[1].Criteria)
+		assert.Equal(t, Position{Line: 5}, decoded.Hosts[0].Pos)
+		assert.Equal(t, Position{Line: 8}, decoded.Hosts[0].Params[1].Pos)
+	}
+}
+
+func TestEncodingSchema(t *testing.T) {
+
+	config, err := Parse(strings.NewReader("Host dev\n  User ubuntu\n"))
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(config)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"blocks": [{"type": "host", "patterns": ["dev"], "line": 1,
+		"params": [{"keyword": "User", "args": ["ubuntu"], "line": 2}]}]}`, string(data))
+}
+
+func TestEncodingInvalidBlock(t *testing.T) {
+
+	config := &Config{}
+	assert.Error(t, json.Unmarshal([]byte(`{"blocks": [{"type": "server", "patterns": ["dev"]}]}`), config))
+	assert.Error(t, json.Unmarshal([]byte(`{"blocks": [{"type": "host"}]}`), config))
+	assert.Error(t, yaml.Unmarshal([]byte("blocks:\n  - type: match\n    patterns: [dev]\n"), config))
+}
@@ -283,16 +283,12 @@ Host dev
 	// # global configuration
 	// # Add ascii art of key, see https://man.openbsd.org/ssh_config.5#VisualHostKey
 	// VisualHostKey yes
-
+	//
 	// # host-based configuration
-
-	// Host dev
-	// 	HostName 127.0.0.1
-	// 	User ubuntu
-	// want:
+	//
 	// Host dev
-	// 	# MITM dont scare me
-	// 	UserKnownHostsFile /dev/null
+	//   HostName 127.0.0.1
+	//   User ubuntu
 }
 
 func ExampleNewHost() {
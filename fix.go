@@ -0,0 +1,283 @@
+package sshconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// ErrFixChangesResolution is returned by Fix when a fix that should
+// preserve behaviour changes the resolved configuration of a sample host
+var ErrFixChangesResolution = errors.New("sshconfig: fix changes the resolved configuration")
+
+// ErrFindingNotInConfig is returned by Fix for findings that refer to
+// nodes of another config, such as the findings of a different Lint
+var ErrFindingNotInConfig = errors.New("sshconfig: finding is not in this config")
+
+// Fix is a set of edits that resolves a lint Finding
+type Fix struct {
+	Description string
+	// ChangesResolution is set when the fix is meant to change what
+	// ssh resolves for some hosts, such as dropping weak algorithms
+	ChangesResolution bool
+
+	edits []edit
+}
+
+// FixOptions controls how Fix applies findings
+type FixOptions struct {
+	// DryRun computes the diff without modifying the config
+	DryRun bool
+	// SampleHosts are resolved before and after each fix to check that
+	// it keeps behaviour unchanged. When empty, every host pattern in
+	// the config without wildcards is used
+	SampleHosts []string
+}
+
+// FixResult reports what Fix changed
+type FixResult struct {
+	Applied []Finding
+	// Diff is a unified diff of the config before and after the fixes
+	Diff string
+}
+
+type editKind int
+
+const (
+	editRename editKind = iota
+	editSetArgs
+	editRemoveParam
+	editMoveHostToEnd
+)
+
+// edit is a single change to a config node
+// host is nil for edits to global parameters
+type edit struct {
+	kind    editKind
+	host    *Host
+	param   *Param
+	keyword string
+	args    []string
+}
+
+// Fix applies the fixes carried by findings to the config in place
+// Findings without a fix are skipped. If any fix that is not marked
+// ChangesResolution alters the resolved configuration of a sample host,
+// nothing is changed and ErrFixChangesResolution is returned. Findings
+// that refer to another config, such as those of Snapshot.Lint, return
+// ErrFindingNotInConfig
+func (config *Config) Fix(findings []Finding, opts FixOptions) (*FixResult, error) {
+
+	samples := opts.SampleHosts
+	if len(samples) == 0 {
+		samples = config.concreteHostnames()
+	}
+
+	work, hosts, params := config.duplicate()
+	result := &FixResult{}
+
+	// check every finding before anything is applied
+	translated := make([][]edit, len(findings))
+	for i, finding := range findings {
+		if finding.Fix == nil {
+			continue
+		}
+		for _, e := range finding.Fix.edits {
+			dup, ok := e.translate(hosts, params)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrFindingNotInConfig, finding.Fix.Description)
+			}
+			translated[i] = append(translated[i], dup)
+		}
+	}
+
+	for i, finding := range findings {
+
+		if finding.Fix == nil {
+			continue
+		}
+
+		before := resolveAll(work, samples)
+
+		for _, e := range translated[i] {
+			work.applyEdit(e)
+		}
+
+		if !finding.Fix.ChangesResolution {
+			after := resolveAll(work, samples)
+			for i, hostname := range samples {
+				if before[i] != after[i] {
+					return nil, fmt.Errorf("%w: %s changes %s", ErrFixChangesResolution, finding.Fix.Description, hostname)
+				}
+			}
+		}
+
+		result.Applied = append(result.Applied, finding)
+
+	}
+
+	diff, err := unifiedDiff(config, work)
+	if err != nil {
+		return nil, err
+	}
+	result.Diff = diff
+
+	if opts.DryRun {
+		return result, nil
+	}
+
+	config.edit(fmt.Sprintf("Fix %d findings", len(result.Applied)), func() error {
+		for _, finding := range result.Applied {
+			for _, e := range finding.Fix.edits {
+				config.applyEdit(e)
+			}
+		}
+		return nil
+	})
+
+	return result, nil
+
+}
+
+func (config *Config) applyEdit(e edit) {
+
+	switch e.kind {
+	case editRename:
+		e.param.Keyword = e.keyword
+	case editSetArgs:
+		e.param.Args = append([]string(nil), e.args...)
+	case editRemoveParam:
+		if e.host != nil {
+			e.host.Params = removeParam(e.host.Params, e.param)
+		} else {
+			config.Globals = removeParam(config.Globals, e.param)
+		}
+	case editMoveHostToEnd:
+		for i, host := range config.Hosts {
+			if host == e.host {
+				config.Hosts = append(append(config.Hosts[:i:i], config.Hosts[i+1:]...), host)
+				break
+			}
+		}
+	}
+
+}
+
+// translate maps an edit onto the copy of a config made by duplicate
+// It reports false when the edit refers to nodes of another config
+func (e edit) translate(hosts map[*Host]*Host, params map[*Param]*Param) (edit, bool) {
+	ok := true
+	if e.host != nil {
+		e.host, ok = hosts[e.host]
+	}
+	if ok && e.param != nil {
+		e.param, ok = params[e.param]
+	}
+	return e, ok
+}
+
+// removeParam drops param from params, handing its comments to the
+// parameter that follows so they are not lost
+func removeParam(params []*Param, param *Param) []*Param {
+	for i, p := range params {
+		if p != param {
+			continue
+		}
+		if i+1 < len(params) && len(p.Comments) > 0 {
+			params[i+1].Comments = append(append([]string(nil), p.Comments...), params[i+1].Comments...)
+		}
+		return append(params[:i:i], params[i+1:]...)
+	}
+	return params
+}
+
+// duplicate returns a deep copy of the config, along with maps from
+// the original nodes to their copies
+func (config *Config) duplicate() (*Config, map[*Host]*Host, map[*Param]*Param) {
+
+	hosts := map[*Host]*Host{}
+	params := map[*Param]*Param{}
+
+	dup := &Config{
+		Source: append([]byte(nil), config.Source...),
+	}
+
+	for _, param := range config.Globals {
+		params[param] = param.Clone()
+		dup.Globals = append(dup.Globals, params[param])
+	}
+
+	for _, host := range config.Hosts {
+		hosts[host] = host.Clone()
+		for i, param := range host.Params {
+			params[param] = hosts[host].Params[i]
+		}
+		dup.Hosts = append(dup.Hosts, hosts[host])
+	}
+
+	return dup, hosts, params
+
+}
+
+// concreteHostnames lists every host pattern without wildcards
+func (config *Config) concreteHostnames() []string {
+	var names []string
+	for _, host := range config.Hosts {
+		for _, hostname := range host.Hostnames {
+			if !isWildcardPattern(hostname) {
+				names = append(names, hostname)
+			}
+		}
+	}
+	return names
+}
+
+// resolveAll resolves every hostname into a comparable form, using
+// canonical keywords so renaming a deprecated alias is not a change
+// Lines are sorted by keyword, since ssh does not care in which order
+// different keywords were set; repeated keywords keep their order
+func resolveAll(config *Config, hostnames []string) []string {
+	resolved := make([]string, len(hostnames))
+	for i, hostname := range hostnames {
+		var lines []string
+		for _, param := range config.Resolve(hostname).Params {
+			lines = append(lines, canonicalKeyword(param.Keyword)+" "+strings.Join(param.Args, " "))
+		}
+		sort.SliceStable(lines, func(a, b int) bool {
+			return strings.Fields(lines[a])[0] < strings.Fields(lines[b])[0]
+		})
+		resolved[i] = strings.Join(lines, "\n")
+	}
+	return resolved
+}
+
+func unifiedDiff(before, after *Config) (string, error) {
+
+	a, b := &bytes.Buffer{}, &bytes.Buffer{}
+
+	if _, err := before.WriteTo(a); err != nil {
+		return "", err
+	}
+	if _, err := after.WriteTo(b); err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(a.String()),
+		B:        splitLines(b.String()),
+		FromFile: "original",
+		ToFile:   "fixed",
+		Context:  3,
+	})
+
+}
+
+// splitLines splits text for difflib, which would otherwise report
+// a phantom empty line after the final newline
+func splitLines(text string) []string {
+	return difflib.SplitLines(strings.TrimSuffix(text, "\n"))
+}
@@ -0,0 +1,140 @@
+package sshconfig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFix(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(lintConfigTest))
+
+	assert.NoError(t, err)
+
+	result, err := config.Fix(config.Lint(), FixOptions{})
+
+	assert.NoError(t, err)
+	assert.Len(t, result.Applied, 4)
+
+	expected := `
+# global configuration
+KbdInteractiveAuthentication no
+Ciphers aes128-ctr
+
+# host-based configuration
+
+Host dev
+  HostName 127.0.0.1
+  User ubuntu
+  IdentityFile ~/.ssh/one
+  IdentityFile ~/.ssh/two
+  Protocol 2
+
+Host *
+  User root
+`
+
+	var b bytes.Buffer
+	config.WriteTo(&b)
+
+	assert.Equal(t, expected, b.String())
+}
+
+func TestFix_DryRun(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host dev
+  User ubuntu
+  User admin
+`))
+
+	assert.NoError(t, err)
+
+	result, err := config.Fix(config.Lint(), FixOptions{DryRun: true})
+
+	assert.NoError(t, err)
+
+	expected := `--- original
++++ fixed
+@@ -5,4 +5,3 @@
+ 
+ Host dev
+   User ubuntu
+-  User admin
+`
+
+	assert.Equal(t, expected, result.Diff)
+	assert.Len(t, config.GetHost("dev").Params, 2)
+}
+
+func TestFix_KeepsComments(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host dev
+  User ubuntu
+  # jump through the bastion
+  User admin
+  ProxyJump bastion
+`))
+
+	assert.NoError(t, err)
+
+	_, err = config.Fix(config.Lint(), FixOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"# jump through the bastion"}, config.GetHost("dev").GetParam(ProxyJumpKeyword).Comments)
+}
+
+func TestFix_ChangesResolution(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host dev
+  User ubuntu
+`))
+
+	assert.NoError(t, err)
+
+	host := config.GetHost("dev")
+	finding := Finding{
+		Rule:  RuleDuplicateKeyword,
+		Host:  host,
+		Param: host.Params[0],
+		Fix: &Fix{
+			Description: "remove User",
+			edits:       []edit{{kind: editRemoveParam, host: host, param: host.Params[0]}},
+		},
+	}
+
+	_, err = config.Fix([]Finding{finding}, FixOptions{})
+
+	assert.ErrorIs(t, err, ErrFixChangesResolution)
+	assert.Len(t, host.Params, 1)
+}
+
+func TestFix_FindingNotInConfig(t *testing.T) {
+
+	source := `
+Host dev
+  ChallengeResponseAuthentication no
+  User ubuntu
+  User admin
+`
+	config, err := Parse(strings.NewReader(source))
+	assert.NoError(t, err)
+	other, err := Parse(strings.NewReader(source))
+	assert.NoError(t, err)
+
+	_, err = config.Fix(other.Lint(), FixOptions{})
+	assert.ErrorIs(t, err, ErrFindingNotInConfig)
+
+	_, err = config.Fix(config.Snapshot().Lint(), FixOptions{})
+	assert.ErrorIs(t, err, ErrFindingNotInConfig)
+
+	// neither config is changed
+	assert.Equal(t, ChallengeResponseAuthenticationKeyword, config.GetHost("dev").Params[0].Keyword)
+	assert.Len(t, config.GetHost("dev").Params, 3)
+	assert.Len(t, other.GetHost("dev").Params, 3)
+}
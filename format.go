@@ -0,0 +1,276 @@
+package sshconfig
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	writerhelper "github.com/petems/go-sshconfig/internal"
+)
+
+// KeywordCase selects how Format spells keywords
+type KeywordCase int
+
+// Keyword casing styles
+const (
+	// KeywordCaseOriginal keeps keywords as they were written, except
+	// for Host and Match, which blocks do not record the spelling of
+	KeywordCaseOriginal KeywordCase = iota
+	// KeywordCaseCanonical uses the spelling from the ssh_config man page
+	KeywordCaseCanonical
+)
+
+// QuoteStyle selects how Format quotes arguments
+type QuoteStyle int
+
+// Argument quoting styles
+const (
+	// QuotePreserve keeps arguments as they were written
+	QuotePreserve QuoteStyle = iota
+	// QuoteMinimal quotes only arguments that contain whitespace
+	QuoteMinimal
+	// QuoteAlways double-quotes every argument
+	QuoteAlways
+)
+
+// FormatOptions controls the layout produced by Format
+type FormatOptions struct {
+	// Indent is the number of spaces host parameters are indented by
+	Indent int
+	// UseTabs indents host parameters with a tab instead of spaces
+	UseTabs bool
+	// KeywordCase selects the spelling of keywords
+	KeywordCase KeywordCase
+	// AlignValues pads keywords so the values in a block line up
+	AlignValues bool
+	// BlankLines is the number of empty lines written between blocks
+	BlankLines int
+	// Headers writes the GlobalConfigurationHeader and
+	// HostConfigurationHeader banners
+	Headers bool
+	// Quote selects how arguments are quoted
+	// Arguments of command keywords such as ProxyCommand are never requoted
+	Quote QuoteStyle
+}
+
+// DefaultFormatOptions returns the options matching the layout of WriteTo
+func DefaultFormatOptions() FormatOptions {
+	return FormatOptions{
+		Indent:     2,
+		BlankLines: 1,
+		Headers:    true,
+	}
+}
+
+// Format parses an ssh config and writes it back out in a canonical layout
+// Formatting only changes whitespace, keyword case and quoting, so the
+// result configures ssh exactly like the input, and formatting the
+// result again returns it unchanged. Comments are kept, including inline
+// comments and those after the last block
+func Format(src []byte, opts FormatOptions) ([]byte, error) {
+
+	config, err := Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := config.WriteFormatted(buf, opts); err != nil {
+		return nil, err
+	}
+
+	// Parse keeps comments after the last global, but not after the last block
+	if len(config.Hosts) > 0 {
+		buf.WriteString(formatComments(trailingComments(src), ""))
+	}
+
+	return buf.Bytes(), nil
+
+}
+
+// WriteFormatted writes a ssh config object to an io.Writer using the given layout
+func (config *Config) WriteFormatted(w io.Writer, opts FormatOptions) (int64, error) {
+
+	var blocks []string
+
+	globals := formatParams(config.Globals, "", opts)
+	if opts.Headers {
+		globals = GlobalConfigurationHeader + "\n" + globals
+	}
+	if globals != "" {
+		blocks = append(blocks, globals)
+	}
+
+	if opts.Headers {
+		blocks = append(blocks, HostConfigurationHeader+"\n")
+	}
+
+	indent := strings.Repeat(" ", opts.Indent)
+	if opts.UseTabs {
+		indent = "\t"
+	}
+
+	for _, host := range config.Hosts {
+		block := formatComments(host.Comments, "")
+		block += host.header() + "\n"
+		block += formatParams(host.Params, indent, opts)
+		blocks = append(blocks, block)
+	}
+
+	wc := writerhelper.NewWriteCounter(w)
+	_, err := io.WriteString(wc, strings.Join(blocks, strings.Repeat("\n", opts.BlankLines)))
+
+	return wc.Written(), err
+
+}
+
+func formatParams(params []*Param, indent string, opts FormatOptions) string {
+
+	width := 0
+	if opts.AlignValues {
+		for _, param := range params {
+			if n := len(formatKeyword(param.Keyword, opts)); n > width {
+				width = n
+			}
+		}
+	}
+
+	buf := &strings.Builder{}
+
+	for _, param := range params {
+		buf.WriteString(formatComments(param.Comments, indent))
+		if param.Keyword == "" {
+			continue
+		}
+		keyword := formatKeyword(param.Keyword, opts)
+		args := formatArgs(param, opts.Quote)
+		if len(args) == 0 {
+			fmt.Fprintf(buf, "%s%s\n", indent, keyword)
+			continue
+		}
+		fmt.Fprintf(buf, "%s%-*s %s\n", indent, width, keyword, strings.Join(args, " "))
+	}
+
+	return buf.String()
+
+}
+
+// trailingComments returns the comment lines that follow the last
+// keyword of src
+func trailingComments(src []byte) []string {
+	var comments []string
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case line[0] == '#':
+			comments = append(comments, line)
+		default:
+			comments = nil
+		}
+	}
+	return comments
+}
+
+func formatComments(comments []string, indent string) string {
+	var formatted string
+	for _, comment := range comments {
+		if !strings.HasPrefix(comment, "#") {
+			comment = "# " + comment
+		}
+		formatted += indent + comment + "\n"
+	}
+	return formatted
+}
+
+func formatKeyword(keyword string, opts FormatOptions) string {
+	if opts.KeywordCase != KeywordCaseCanonical {
+		return keyword
+	}
+	if info, ok := LookupKeyword(keyword); ok {
+		return info.Name
+	}
+	return keyword
+}
+
+func formatArgs(param *Param, style QuoteStyle) []string {
+
+	if info, ok := LookupKeyword(param.Keyword); style == QuotePreserve || (ok && info.Command) {
+		return param.Args
+	}
+
+	var args []string
+	words, comment := splitArgs(strings.Join(param.Args, " "))
+	for _, word := range words {
+		if style == QuoteAlways || word == "" || strings.ContainsAny(word, " \t\"'\\") || strings.HasPrefix(word, "#") {
+			word = quoteWord(word)
+		}
+		args = append(args, word)
+	}
+	if comment != "" {
+		args = append(args, comment)
+	}
+
+	return args
+
+}
+
+// quoteWord quotes a word so that splitArgs reads it back unchanged
+func quoteWord(word string) string {
+	if strings.Contains(word, `"`) && !strings.ContainsAny(word, `'\`) {
+		return "'" + word + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(word) + `"`
+}
+
+// splitArgs splits an argument string into words the way ssh does,
+// treating text inside single or double quotes as part of one word
+// A backslash escapes a quote, a backslash, or outside quotes a space;
+// before anything else it is kept. An unquoted # at the start of a word
+// begins a comment, which is returned unchanged after the words
+func splitArgs(s string) ([]string, string) {
+
+	var (
+		words   []string
+		comment string
+		word    strings.Builder
+		inWord  bool
+		quoting rune
+	)
+
+	runes := []rune(s)
+	for i := 0; i < len(runes) && comment == ""; i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && (strings.ContainsRune(`"'\`, runes[i+1]) || (quoting == 0 && runes[i+1] == ' ')):
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case quoting != 0 && r == quoting:
+			quoting = 0
+		case quoting != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quoting, inWord = r, true
+		case r == '#' && !inWord:
+			comment = string(runes[i:])
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, comment
+
+}
@@ -0,0 +1,169 @@
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var formatConfigTest = `
+# global configuration
+visualhostkey yes
+# our keys
+IdentityFile "~/.ssh/my key"
+
+# host-based configuration
+Host dev
+    hostname 127.0.0.1
+	User ubuntu
+  # proxy through the bastion
+  ProxyCommand ssh -W "%h:%p" bastion
+
+# fallback
+host *
+  User "root"
+`
+
+func TestFormat_Default(t *testing.T) {
+
+	formatted, err := Format([]byte(sshConfigTest), DefaultFormatOptions())
+
+	assert.NoError(t, err)
+	assert.Equal(t, strings.TrimPrefix(sshConfigTest, "\n"), string(formatted))
+}
+
+func TestFormat_Options(t *testing.T) {
+
+	opts := FormatOptions{
+		UseTabs:     true,
+		KeywordCase: KeywordCaseCanonical,
+		AlignValues: true,
+		BlankLines:  2,
+		Quote:       QuoteMinimal,
+	}
+
+	formatted, err := Format([]byte(formatConfigTest), opts)
+
+	assert.NoError(t, err)
+
+	expected := `VisualHostKey yes
+# our keys
+IdentityFile  "~/.ssh/my key"
+
+
+Host dev
+	HostName     127.0.0.1
+	User         ubuntu
+	# proxy through the bastion
+	ProxyCommand ssh -W "%h:%p" bastion
+
+
+# fallback
+Host *
+	User root
+`
+
+	assert.Equal(t, expected, string(formatted))
+}
+
+func TestFormat_Idempotent(t *testing.T) {
+
+	for _, opts := range []FormatOptions{
+		DefaultFormatOptions(),
+		{Indent: 4, AlignValues: true, Quote: QuoteAlways},
+		{UseTabs: true, KeywordCase: KeywordCaseCanonical, Headers: true, Quote: QuoteMinimal},
+	} {
+		once, err := Format([]byte(formatConfigTest), opts)
+		assert.NoError(t, err)
+
+		twice, err := Format(once, opts)
+		assert.NoError(t, err)
+
+		assert.Equal(t, string(once), string(twice))
+	}
+}
+
+func TestFormat_KeepsResolution(t *testing.T) {
+
+	words := func(config *Config, hostname string) [][]string {
+		var resolved [][]string
+		for _, param := range config.Resolve(hostname).Params {
+			args, comment := splitArgs(strings.Join(param.Args, " "))
+			resolved = append(resolved, append(append([]string{canonicalKeyword(param.Keyword)}, args...), comment))
+		}
+		return resolved
+	}
+
+	original, err := Parse(strings.NewReader(formatConfigTest))
+	assert.NoError(t, err)
+
+	formatted, err := Format([]byte(formatConfigTest), FormatOptions{Quote: QuoteAlways, KeywordCase: KeywordCaseCanonical})
+	assert.NoError(t, err)
+
+	config, err := Parse(strings.NewReader(string(formatted)))
+	assert.NoError(t, err)
+
+	for _, hostname := range []string{"dev", "other"} {
+		assert.Equal(t, words(original, hostname), words(config, hostname))
+	}
+
+	assert.Equal(t, []string{"ssh", "-W", `"%h:%p"`, "bastion"}, config.GetHost("dev").GetParam(ProxyCommandKeyword).Args)
+}
+
+func TestFormat_Escapes(t *testing.T) {
+
+	words, comment := splitArgs(`"a \"b\"" c\ d 'e\'f' C:\path g\\`)
+	assert.Equal(t, []string{`a "b"`, "c d", "e'f", `C:\path`, `g\`}, words)
+	assert.Equal(t, "", comment)
+
+	src := "SetEnv GREETING=\"hello \\\"world\\\"\" PATH=C:\\\\bin\n"
+	for _, quote := range []QuoteStyle{QuoteMinimal, QuoteAlways} {
+		formatted, err := Format([]byte(src), FormatOptions{Quote: quote})
+		assert.NoError(t, err)
+
+		config, err := Parse(strings.NewReader(string(formatted)))
+		assert.NoError(t, err)
+		words, _ := splitArgs(strings.Join(config.Globals[0].Args, " "))
+		assert.Equal(t, []string{`GREETING=hello "world"`, `PATH=C:\bin`}, words, string(formatted))
+	}
+}
+
+func TestFormat_Comments(t *testing.T) {
+
+	words, comment := splitArgs(`x "#quoted" a#b # inline "comment"`)
+	assert.Equal(t, []string{"x", "#quoted", "a#b"}, words)
+	assert.Equal(t, `# inline "comment"`, comment)
+
+	src := `User x # inline
+
+Host dev
+  SendEnv "#quoted" LANG # not sent
+  # last param
+
+# after the last block
+`
+	for _, quote := range []QuoteStyle{QuotePreserve, QuoteMinimal, QuoteAlways} {
+		opts := DefaultFormatOptions()
+		opts.Headers, opts.Quote = false, quote
+		formatted, err := Format([]byte(src), opts)
+		assert.NoError(t, err)
+
+		// comments closing the last block are kept, unindented
+		want := `User x # inline
+
+Host dev
+  SendEnv "#quoted" LANG # not sent
+# last param
+# after the last block
+`
+		if quote == QuoteAlways {
+			want = strings.NewReplacer("x #", `"x" #`, "LANG #", `"LANG" #`).Replace(want)
+		}
+		assert.Equal(t, want, string(formatted))
+
+		twice, err := Format(formatted, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, string(formatted), string(twice))
+	}
+}
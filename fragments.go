@@ -0,0 +1,467 @@
+package sshconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Errors returned by FragmentManager
+var (
+	ErrNoSuchFragment  = errors.New("sshconfig: no such fragment")
+	ErrFragmentExists  = errors.New("sshconfig: fragment already exists")
+	ErrInvalidFragment = errors.New("sshconfig: invalid fragment name")
+)
+
+// defaultFragmentDir is where fragments are kept, next to the main config
+const defaultFragmentDir = "config.d"
+
+// FragmentManager keeps each host in its own file, a fragment, in a
+// directory next to a main config that loads them all with an Include
+// Fragments are read in lexical order of their names, like ssh expands
+// the Include, so the first matching fragment wins
+type FragmentManager struct {
+	// FS holds the files; use DirFS(filepath.Join(home, ".ssh")) for
+	// the config of the current user
+	FS WritableFS
+	// Config is the name of the main config in FS, such as "config"
+	Config string
+	// Dir is the name of the fragment directory in FS; it defaults to
+	// config.d next to Config, and must be below the directory of Config
+	Dir string
+}
+
+// dir returns the fragment directory
+func (manager *FragmentManager) dir() string {
+	if manager.Dir == "" {
+		return path.Join(path.Dir(manager.Config), defaultFragmentDir)
+	}
+	return path.Clean(manager.Dir)
+}
+
+// includePattern returns the Include argument that loads the fragments,
+// relative to the directory of the main config like ssh expects
+func (manager *FragmentManager) includePattern() (string, error) {
+
+	base, dir := path.Dir(manager.Config), manager.dir()
+	if base == "." {
+		return dir + "/*", nil
+	}
+	if !strings.HasPrefix(dir, base+"/") {
+		return "", fmt.Errorf("%w: %s is not below %s", ErrInvalidFragment, dir, base)
+	}
+
+	return strings.TrimPrefix(dir, base+"/") + "/*", nil
+
+}
+
+// Path returns the name of a fragment in FS
+func (manager *FragmentManager) Path(name string) string {
+	return path.Join(manager.dir(), name)
+}
+
+// List returns the names of the fragments, in the order ssh reads them
+func (manager *FragmentManager) List() ([]string, error) {
+
+	entries, err := fs.ReadDir(manager.FS, manager.dir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+
+}
+
+// Load parses a fragment
+func (manager *FragmentManager) Load(name string) (*Config, error) {
+
+	if err := checkFragmentName(name); err != nil {
+		return nil, err
+	}
+
+	config, err := ParseFS(manager.FS, manager.Path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchFragment, name)
+	}
+
+	return config, err
+
+}
+
+// Create writes host to a new fragment named after its first pattern,
+// makes sure the main config includes the fragments and returns the
+// name of the fragment
+func (manager *FragmentManager) Create(host *Host) (string, error) {
+
+	name := fragmentName(host)
+	if err := manager.create(name, &Config{Hosts: []*Host{host}}); err != nil {
+		return "", err
+	}
+
+	return name, manager.EnsureInclude()
+
+}
+
+func (manager *FragmentManager) create(name string, config *Config) error {
+
+	if err := checkFragmentName(name); err != nil {
+		return err
+	}
+	if _, err := fs.Stat(manager.FS, manager.Path(name)); err == nil {
+		return fmt.Errorf("%w: %s", ErrFragmentExists, name)
+	}
+
+	return config.Save(manager.Path(name), SaveOptions{FS: manager.FS})
+
+}
+
+// Rename renames a fragment, and the pattern of its host that matches
+// the old name, if any
+func (manager *FragmentManager) Rename(old, new string) error {
+
+	config, err := manager.Load(old)
+	if err != nil {
+		return err
+	}
+	for _, host := range config.Hosts {
+		if err := host.RenamePattern(old, new); err == nil {
+			break
+		}
+	}
+
+	// the new file does not exist yet
+	config.Source = nil
+	if err := manager.create(new, config); err != nil {
+		return err
+	}
+
+	return manager.FS.Remove(manager.Path(old))
+
+}
+
+// Delete removes a fragment
+func (manager *FragmentManager) Delete(name string) error {
+
+	if err := checkFragmentName(name); err != nil {
+		return err
+	}
+
+	err := manager.FS.Remove(manager.Path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNoSuchFragment, name)
+	}
+
+	return err
+
+}
+
+// EnsureInclude makes sure the main config includes the fragments at the
+// top level, creating the main config when it does not exist
+// The Include is added as the first global parameter, where fragments
+// take precedence over the rest of the main config. An Include of the
+// fragments inside a Host or Match block only applies to that block, so
+// it is removed from the block
+func (manager *FragmentManager) EnsureInclude() error {
+
+	pattern, err := manager.includePattern()
+	if err != nil {
+		return err
+	}
+	config, err := manager.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	for _, param := range config.Globals {
+		if includesPattern(param, pattern) {
+			return nil
+		}
+	}
+	for _, host := range config.Hosts {
+		host.Params = removeInclude(host.Params, pattern)
+	}
+	config.Globals = slices.Insert(config.Globals, 0, NewParam(IncludeKeyword, []string{pattern}, nil))
+
+	return config.Save(manager.Config, SaveOptions{FS: manager.FS})
+
+}
+
+// Split moves every block of the main config into a fragment of its own
+// and includes the fragments as near the top as hosts still resolve as
+// before: after the last global that is an Include or that a block could
+// override
+// Fragments are named after the first pattern of their block; when that
+// would read them in a different order, or give two the same name,
+// every name is prefixed with the position of its block. Split refuses
+// to run when fragments exist already
+func (manager *FragmentManager) Split() ([]string, error) {
+
+	pattern, err := manager.includePattern()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := manager.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrFragmentExists, existing[0])
+	}
+	config, err := manager.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(config.Hosts))
+	for i, host := range config.Hosts {
+		names[i] = fragmentName(host)
+	}
+	if !sort.StringsAreSorted(names) || hasDuplicates(names) {
+		width := len(fmt.Sprint(len(names)))
+		for i := range names {
+			names[i] = fmt.Sprintf("%0*d-%s", width, i+1, names[i])
+		}
+	}
+
+	for i, host := range config.Hosts {
+		if err := manager.create(names[i], &Config{Hosts: []*Host{host}}); err != nil {
+			return nil, err
+		}
+	}
+
+	globals := removeInclude(config.Globals, pattern)
+	at := includePosition(globals, config.Hosts)
+	config.Hosts = nil
+	config.Globals = slices.Insert(globals, at, NewParam(IncludeKeyword, []string{pattern}, nil))
+	if err := config.Save(manager.Config, SaveOptions{FS: manager.FS}); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+
+}
+
+// Join replaces the Include of the fragments in the main config with
+// their contents and removes them, so hosts resolve as before
+// Blocks that the fragments would otherwise end early are continued as
+// they are by IncludeTree.Config
+func (manager *FragmentManager) Join() error {
+
+	pattern, err := manager.includePattern()
+	if err != nil {
+		return err
+	}
+	names, err := manager.List()
+	if err != nil {
+		return err
+	}
+	config, err := manager.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	tree := &IncludeTree{
+		Root:     manager.Config,
+		Files:    []*ConfigFile{{Path: manager.Config, Config: config}},
+		includes: map[*Param][]string{},
+	}
+	var paths []string
+	for _, name := range names {
+		fragment, err := manager.Load(name)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, manager.Path(name))
+		tree.Files = append(tree.Files, &ConfigFile{Path: manager.Path(name), Config: fragment})
+	}
+
+	found := false
+	config.Globals = splitIncludes(config.Globals, pattern)
+	for _, host := range config.Hosts {
+		host.Params = splitIncludes(host.Params, pattern)
+	}
+	for _, param := range tree.Files[0].params() {
+		if includesPattern(param, pattern) {
+			tree.includes[param] = paths
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s %s", ErrNoSuchParam, IncludeKeyword, pattern)
+	}
+
+	joined := tree.Config()
+	joined.Source = config.Source
+	if err := joined.Save(manager.Config, SaveOptions{FS: manager.FS}); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := manager.Delete(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// loadConfig parses the main config, which is empty when it does not exist
+func (manager *FragmentManager) loadConfig() (*Config, error) {
+	config, err := ParseFS(manager.FS, manager.Config)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	return config, err
+}
+
+// fragmentName returns a file name for a block from its first pattern
+func fragmentName(host *Host) string {
+
+	name := "match"
+	if len(host.Hostnames) > 0 {
+		name = host.Hostnames[0]
+	} else if len(host.Criteria) > 0 {
+		name = "match-" + strings.Join(host.Criteria, "-")
+	}
+
+	name = strings.NewReplacer("*", "all", "?", "_", "!", "not-").Replace(name)
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+
+	return strings.TrimLeft(name, ".")
+
+}
+
+// checkFragmentName rejects names that are not a plain file ssh would
+// include; like glob(3), the Include wildcard skips names starting with
+// a dot
+func checkFragmentName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%w: %q", ErrInvalidFragment, name)
+	}
+	return nil
+}
+
+// includesPattern reports whether param is an Include of pattern
+func includesPattern(param *Param, pattern string) bool {
+	if !strings.EqualFold(param.Keyword, IncludeKeyword) {
+		return false
+	}
+	for _, arg := range param.Args {
+		if strings.Trim(arg, `"`) == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// includePosition returns where in globals an Include of blocks leaves
+// resolution unchanged, as near the top as possible
+// Globals read before the Include win over the blocks, so it follows
+// every global setting a keyword the blocks set, every Include, whose
+// blocks would otherwise come later, and, when there are Match blocks,
+// the HostName and User they are evaluated against
+func includePosition(globals []*Param, blocks []*Host) int {
+
+	keywords := map[string]bool{
+		strings.ToLower(IncludeKeyword): true,
+	}
+	for _, host := range blocks {
+		if host.IsMatch() {
+			keywords[strings.ToLower(HostNameKeyword)] = true
+			keywords[strings.ToLower(UserKeyword)] = true
+		}
+		for _, param := range host.Params {
+			keywords[canonicalKeyword(param.Keyword)] = true
+		}
+	}
+
+	at := 0
+	for i, param := range globals {
+		if param.Keyword != "" && keywords[canonicalKeyword(param.Keyword)] {
+			at = i + 1
+		}
+	}
+	return at
+
+}
+
+// removeInclude removes pattern from the Include parameters in params,
+// and the parameters left without arguments
+func removeInclude(params []*Param, pattern string) []*Param {
+
+	var kept []*Param
+	for _, param := range params {
+		if !includesPattern(param, pattern) {
+			kept = append(kept, param)
+			continue
+		}
+		var args []string
+		for _, arg := range param.Args {
+			if strings.Trim(arg, `"`) != pattern {
+				args = append(args, arg)
+			}
+		}
+		if len(args) > 0 {
+			param.Args = args
+			kept = append(kept, param)
+		}
+	}
+
+	return kept
+
+}
+
+// splitIncludes gives pattern an Include parameter of its own wherever
+// an Include lists it among others, which ssh reads the same way
+func splitIncludes(params []*Param, pattern string) []*Param {
+
+	var split []*Param
+	for _, param := range params {
+		if !includesPattern(param, pattern) || len(param.Args) == 1 {
+			split = append(split, param)
+			continue
+		}
+		for i, arg := range param.Args {
+			var comments []string
+			if i == 0 {
+				comments = param.Comments
+			}
+			split = append(split, &Param{Comments: comments, Keyword: param.Keyword, Args: []string{arg}, Pos: param.Pos})
+		}
+	}
+
+	return split
+
+}
+
+func hasDuplicates(names []string) bool {
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			return true
+		}
+		seen[name] = true
+	}
+	return false
+}
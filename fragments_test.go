@@ -0,0 +1,171 @@
+package sshconfig
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFragmentManager(t *testing.T) {
+
+	fsys := NewMemFS(map[string]string{".ssh/config": "Host dev\n  Include config.d/*\n  User ubuntu\n"})
+	manager := &FragmentManager{FS: fsys, Config: ".ssh/config"}
+
+	names, err := manager.List()
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	web := NewHost([]string{"web", "web.example.com"}, nil)
+	web.AddParam(NewParam(UserKeyword, []string{"deploy"}, nil))
+	name, err := manager.Create(web)
+	assert.NoError(t, err)
+	assert.Equal(t, "web", name)
+
+	_, err = manager.Create(NewHost([]string{"web"}, nil))
+	assert.ErrorIs(t, err, ErrFragmentExists)
+	_, err = manager.Create(NewHost([]string{"*.internal"}, nil))
+	assert.NoError(t, err)
+
+	names, err = manager.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"all.internal", "web"}, names)
+
+	// the Include is moved out of the dev block to the top level
+	main, err := ParseFS(fsys, ".ssh/config")
+	assert.NoError(t, err)
+	assert.Equal(t, "Include config.d/*", paramLine(main.Globals[0]))
+	assert.Len(t, main.Hosts[0].Params, 1)
+
+	assert.NoError(t, manager.Rename("web", "www"))
+	_, err = manager.Load("web")
+	assert.ErrorIs(t, err, ErrNoSuchFragment)
+	www, err := manager.Load("www")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"www", "web.example.com"}, www.Hosts[0].Hostnames)
+
+	assert.NoError(t, manager.Delete("www"))
+	assert.ErrorIs(t, manager.Delete("www"), ErrNoSuchFragment)
+	assert.ErrorIs(t, manager.Delete("../config"), ErrInvalidFragment)
+}
+
+func TestFragmentManagerSplitJoin(t *testing.T) {
+
+	source := `Include ~/.orbstack/ssh/config
+ForwardAgent no
+
+# dev box
+Host dev
+  HostName 10.0.0.2
+  User ubuntu
+
+Host *
+  User nobody
+  ServerAliveInterval 30
+
+Match host bastion
+  Port 2222
+`
+	fsys := NewMemFS(map[string]string{
+		".ssh/config":                 source,
+		".orbstack/ssh/config":        "Host orb\n  User orb\n",
+		".ssh/config.d/.keep":         "",
+		".ssh/config.d/subdir/ignore": "",
+	})
+	manager := &FragmentManager{FS: fsys, Config: ".ssh/config"}
+	hostnames := []string{"dev", "bastion", "orb", "other"}
+
+	resolve := func() []string {
+		tree, err := LoadFile(".ssh/config", LoadOptions{FS: fsys})
+		assert.NoError(t, err)
+		return resolveAll(tree.Config(), hostnames)
+	}
+	before := resolve()
+
+	assert.NoError(t, fsys.Remove(".ssh/config.d/.keep"))
+	names, err := manager.Split()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1-dev", "2-all", "3-match-host-bastion"}, names)
+	assert.Equal(t, before, resolve())
+
+	data, err := fs.ReadFile(fsys, ".ssh/config")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "Include ~/.orbstack/ssh/config\nInclude config.d/*\nForwardAgent no\n")
+	assert.NotContains(t, string(data), "Host")
+
+	dev, err := fs.ReadFile(fsys, ".ssh/config.d/1-dev")
+	assert.NoError(t, err)
+	assert.Contains(t, string(dev), "# dev box\nHost dev\n")
+
+	_, err = manager.Split()
+	assert.ErrorIs(t, err, ErrFragmentExists)
+
+	assert.NoError(t, manager.Join())
+	assert.Equal(t, before, resolve())
+
+	names, err = manager.List()
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	data, err = fs.ReadFile(fsys, ".ssh/config")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "config.d")
+	assert.ErrorIs(t, manager.Join(), ErrNoSuchParam)
+}
+
+func TestFragmentManagerIncludePosition(t *testing.T) {
+
+	fsys := NewMemFS(map[string]string{".ssh/config": "# defaults\nUser admin\nForwardAgent no\n\nHost dev\n  HostName 10.0.0.2\n"})
+	manager := &FragmentManager{FS: fsys, Config: ".ssh/config"}
+
+	// fragments come before every global, so they take precedence
+	assert.NoError(t, manager.EnsureInclude())
+	main, err := ParseFS(fsys, ".ssh/config")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Include config.d/*", "User admin", "ForwardAgent no"}, []string{
+		paramLine(main.Globals[0]), paramLine(main.Globals[1]), paramLine(main.Globals[2]),
+	})
+	assert.Equal(t, []string{"# defaults"}, main.Globals[1].Comments)
+
+	// Split keeps globals that a block sets, and Includes, ahead of it
+	globals := []*Param{
+		NewParam(ForwardAgentKeyword, []string{"no"}, nil),
+		NewParam(UserKeyword, []string{"admin"}, nil),
+		NewParam(CompressionKeyword, []string{"yes"}, nil),
+	}
+	block := NewHost([]string{"dev"}, nil)
+	block.AddParam(NewParam(UserKeyword, []string{"ubuntu"}, nil))
+	assert.Equal(t, 2, includePosition(globals, []*Host{block}))
+	assert.Equal(t, 0, includePosition(globals, []*Host{NewHost([]string{"dev"}, nil)}))
+	assert.Equal(t, 2, includePosition(globals, []*Host{NewMatch([]string{"host", "dev"}, nil)}))
+}
+
+func TestFragmentManagerJoinContinuesBlock(t *testing.T) {
+
+	fsys := NewMemFS(map[string]string{
+		"config":      "Host dev\n  Include frags/* /etc/extra\n  User ubuntu\n",
+		"frags/other": "Host other\n  User root\n",
+	})
+	manager := &FragmentManager{FS: fsys, Config: "config", Dir: "frags"}
+
+	assert.NoError(t, manager.Join())
+
+	config, err := ParseFS(fsys, "config")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Host dev", "Host other", "Host dev"}, mutateHeaders(config))
+	assert.Equal(t, "Include /etc/extra", paramLine(config.Hosts[2].Params[0]))
+	assert.Equal(t, "ubuntu", config.Resolve("dev").GetParam(UserKeyword).Value())
+	assert.Equal(t, "root", config.Resolve("other").GetParam(UserKeyword).Value())
+
+	data, err := fs.ReadFile(fsys, "config")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "frags")
+}
+
+func TestFragmentName(t *testing.T) {
+
+	assert.Equal(t, "all", fragmentName(NewHost([]string{"*"}, nil)))
+	assert.Equal(t, "web-_", fragmentName(NewHost([]string{"web-?"}, nil)))
+	assert.Equal(t, "match-all", fragmentName(NewMatch([]string{"all"}, nil)))
+	assert.Equal(t, "example.com", fragmentName(NewHost([]string{".example.com"}, nil)))
+}
@@ -0,0 +1,333 @@
+package sshconfig
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReadOnlyFS is returned when saving to a filesystem that cannot be written
+var ErrReadOnlyFS = errors.New("sshconfig: filesystem is read-only")
+
+// WritableFS is a filesystem config files can be saved to
+// Names are slash-separated paths as accepted by fs.ValidPath
+type WritableFS interface {
+	fs.FS
+	// WriteFile replaces the named file with data, or creates it with
+	// mode 0600, without leaving a partly written file behind
+	WriteFile(name string, data []byte) error
+	// Remove deletes the named file
+	Remove(name string) error
+}
+
+// DirFS returns a writable filesystem for the directory tree rooted at
+// dir, which writes files with the same care as WriteToFilepath
+func DirFS(dir string) WritableFS {
+	return &dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (fsys *dirFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(fsys.dir, filepath.FromSlash(name)), nil
+}
+
+func (fsys *dirFS) WriteFile(name string, data []byte) error {
+	path, err := fsys.path("write", name)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, WriteOptions{})
+}
+
+func (fsys *dirFS) Remove(name string) error {
+	path, err := fsys.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// MemFS is an in-memory WritableFS, safe for concurrent use
+// Directories exist implicitly whenever they hold a file
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string]*memFile
+}
+
+// memFile is the contents of a MemFS file
+type memFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewMemFS creates a MemFS holding a copy of files, keyed by path
+func NewMemFS(files map[string]string) *MemFS {
+	fsys := &MemFS{files: map[string]*memFile{}}
+	for name, data := range files {
+		fsys.files[name] = &memFile{data: []byte(data), mode: 0600, modTime: time.Now()}
+	}
+	return fsys
+}
+
+// Open opens the named file or directory
+func (fsys *MemFS) Open(name string) (fs.File, error) {
+
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	info, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &memDir{info: info, entries: fsys.entries(name)}, nil
+	}
+	return &memOpenFile{info: info, Reader: bytes.NewReader(fsys.files[name].data)}, nil
+
+}
+
+// ReadFile returns a copy of the contents of the named file
+func (fsys *MemFS) ReadFile(name string) ([]byte, error) {
+
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	info, err := fsys.stat("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return append([]byte(nil), fsys.files[name].data...), nil
+
+}
+
+// Stat describes the named file or directory
+func (fsys *MemFS) Stat(name string) (fs.FileInfo, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	return fsys.stat("stat", name)
+}
+
+// ReadDir lists the named directory
+func (fsys *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	info, err := fsys.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return fsys.entries(name), nil
+
+}
+
+// Glob returns the names of the files matching pattern
+func (fsys *MemFS) Glob(pattern string) ([]string, error) {
+	// hide this method from fs.Glob, which would call it again
+	return fs.Glob(struct{ fs.ReadDirFS }{fsys}, pattern)
+}
+
+// WriteFile replaces or creates the named file, keeping the mode of an
+// existing file
+func (fsys *MemFS) WriteFile(name string, data []byte) error {
+
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	var mode fs.FileMode = 0600
+	if info, err := fsys.stat("write", name); err == nil {
+		if info.IsDir() {
+			return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+		}
+		mode = info.Mode()
+	}
+	fsys.files[name] = &memFile{data: append([]byte(nil), data...), mode: mode, modTime: time.Now()}
+
+	return nil
+
+}
+
+// Remove deletes the named file
+func (fsys *MemFS) Remove(name string) error {
+
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	if _, ok := fsys.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(fsys.files, name)
+
+	return nil
+
+}
+
+// stat describes a file, or a directory when some file is below name
+// The caller holds the lock
+func (fsys *MemFS) stat(op, name string) (fs.FileInfo, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	if file, ok := fsys.files[name]; ok {
+		return &memInfo{name: path.Base(name), size: int64(len(file.data)), mode: file.mode, modTime: file.modTime}, nil
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	for file := range fsys.files {
+		if strings.HasPrefix(file, prefix) {
+			return &memInfo{name: path.Base(name), mode: fs.ModeDir | 0700}, nil
+		}
+	}
+
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+
+}
+
+// entries lists the directory name, sorted by name
+// The caller holds the lock
+func (fsys *MemFS) entries(name string) []fs.DirEntry {
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	seen := map[string]bool{}
+	var entries []fs.DirEntry
+	for file := range fsys.files {
+		rest, ok := strings.CutPrefix(file, prefix)
+		if !ok {
+			continue
+		}
+		child, _, _ := strings.Cut(rest, "/")
+		if seen[child] {
+			continue
+		}
+		seen[child] = true
+		info, _ := fsys.stat("readdir", path.Join(name, child))
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+
+}
+
+// memInfo describes a MemFS file or directory
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (info *memInfo) Name() string       { return info.name }
+func (info *memInfo) Size() int64        { return info.size }
+func (info *memInfo) Mode() fs.FileMode  { return info.mode }
+func (info *memInfo) ModTime() time.Time { return info.modTime }
+func (info *memInfo) IsDir() bool        { return info.mode.IsDir() }
+func (info *memInfo) Sys() interface{}   { return nil }
+
+// memOpenFile is an open MemFS file
+// It reads a snapshot of the contents, so later writes do not affect it
+type memOpenFile struct {
+	info fs.FileInfo
+	*bytes.Reader
+}
+
+func (file *memOpenFile) Stat() (fs.FileInfo, error) { return file.info, nil }
+func (file *memOpenFile) Close() error               { return nil }
+
+// memDir is an open MemFS directory
+type memDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (dir *memDir) Stat() (fs.FileInfo, error) { return dir.info, nil }
+func (dir *memDir) Close() error               { return nil }
+
+func (dir *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.info.Name(), Err: errors.New("is a directory")}
+}
+
+// ReadDir follows the semantics of fs.ReadDirFile
+func (dir *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := dir.entries[dir.offset:]
+	if n <= 0 {
+		dir.offset = len(dir.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	dir.offset += n
+	return rest[:n], nil
+}
+
+// rotateBackupsFS keeps count previous versions of the named file, like
+// rotateBackups does on disk
+func rotateBackupsFS(fsys WritableFS, name string, count int) error {
+
+	backup := func(n int) string {
+		return name + ".bak." + strconv.Itoa(n)
+	}
+
+	for n := count; n >= 1; n-- {
+		from := name
+		if n > 1 {
+			from = backup(n - 1)
+		}
+		data, err := fs.ReadFile(fsys, from)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fsys.WriteFile(backup(n), data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
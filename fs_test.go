@@ -0,0 +1,173 @@
+package sshconfig
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemFS(t *testing.T) {
+
+	fsys := NewMemFS(map[string]string{".ssh/config": "Host dev\n"})
+
+	assert.NoError(t, fstest.TestFS(fsys, ".ssh/config"))
+
+	assert.NoError(t, fsys.WriteFile(".ssh/config.d/work", []byte("Host work\n")))
+	data, err := fs.ReadFile(fsys, ".ssh/config.d/work")
+	assert.NoError(t, err)
+	assert.Equal(t, "Host work\n", string(data))
+
+	entries, err := fs.ReadDir(fsys, ".ssh")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.NoError(t, fsys.Remove(".ssh/config.d/work"))
+	assert.ErrorIs(t, fsys.Remove(".ssh/config.d/work"), fs.ErrNotExist)
+	assert.ErrorIs(t, fsys.WriteFile("/etc/passwd", nil), fs.ErrInvalid)
+}
+
+func TestLoadFileFS(t *testing.T) {
+
+	fsys := NewMemFS(map[string]string{
+		".ssh/config": `Include config.d/*
+Include ~/.ssh/extra /etc/ssh/ssh_config.d/*.conf
+
+Host dev
+  User ubuntu
+`,
+		".ssh/config.d/work":               "Host work\n  User employee\n",
+		".ssh/extra":                       "Host *\n  ServerAliveInterval 30\n",
+		"etc/ssh/ssh_config.d/crypto.conf": "Ciphers aes256-gcm@openssh.com\n",
+	})
+
+	tree, err := LoadFile(".ssh/config", LoadOptions{FS: fsys})
+	assert.NoError(t, err)
+
+	var paths []string
+	for _, file := range tree.Files {
+		paths = append(paths, file.Path)
+	}
+	assert.Equal(t, []string{".ssh/config", ".ssh/config.d/work", ".ssh/extra", "etc/ssh/ssh_config.d/crypto.conf"}, paths)
+
+	dev := tree.Config().Resolve("dev")
+	assert.Equal(t, "ubuntu", dev.GetParam(UserKeyword).Value())
+	assert.Equal(t, "30", dev.GetParam(ServerAliveIntervalKeyword).Value())
+	assert.Equal(t, "aes256-gcm@openssh.com", dev.GetParam(CiphersKeyword).Value())
+
+	tree.File(".ssh/config.d/work").Config.Hosts[0].SetParam(UserKeyword, "contractor")
+
+	written, err := tree.Save(SaveOptions{Backups: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".ssh/config.d/work"}, written)
+
+	data, err := fs.ReadFile(fsys, ".ssh/config.d/work")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "Host work\n  User contractor\n")
+
+	backup, err := fs.ReadFile(fsys, ".ssh/config.d/work.bak.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Host work\n  User employee\n", string(backup))
+}
+
+func TestLoadFileReadOnlyFS(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"config": &fstest.MapFile{Data: []byte("Host dev\n  User ubuntu\n")},
+	}
+
+	tree, err := LoadFile("config", LoadOptions{FS: fsys})
+	assert.NoError(t, err)
+
+	written, err := tree.Save(SaveOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, written)
+
+	tree.File("config").Config.Hosts[0].SetParam(UserKeyword, "root")
+
+	_, err = tree.Save(SaveOptions{})
+	assert.ErrorIs(t, err, ErrReadOnlyFS)
+}
+
+func TestSaveFS(t *testing.T) {
+
+	fsys := NewMemFS(map[string]string{"config": "Host dev\n"})
+
+	config, err := ParseFS(fsys, "config")
+	assert.NoError(t, err)
+	assert.Equal(t, "config", config.Hosts[0].Pos.File)
+
+	assert.ErrorIs(t, config.Save("config", SaveOptions{FS: fsys, Lock: true}), ErrLockNotSupported)
+
+	assert.NoError(t, fsys.WriteFile("config", []byte("Host other\n")))
+	assert.ErrorIs(t, config.Save("config", SaveOptions{FS: fsys}), ErrConflict)
+	assert.NoError(t, config.Save("config", SaveOptions{FS: fsys, Force: true}))
+
+	data, err := fs.ReadFile(fsys, "config")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "Host dev\n")
+	assert.NotContains(t, string(data), "Host other")
+}
+
+func TestDirFS(t *testing.T) {
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config"), []byte("Host dev\n"), 0644))
+
+	fsys := DirFS(dir)
+	assert.NoError(t, fsys.WriteFile("config", []byte("Host prod\n")))
+
+	data, err := os.ReadFile(filepath.Join(dir, "config"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Host prod\n", string(data))
+
+	info, err := os.Stat(filepath.Join(dir, "config"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	assert.NoError(t, fsys.Remove("config"))
+	assert.ErrorIs(t, fsys.Remove("../config"), fs.ErrInvalid)
+}
+
+func TestCheckIdentityFiles(t *testing.T) {
+
+	fsys := NewMemFS(map[string]string{
+		".ssh/config": `IdentityFile ~/.ssh/id_ed25519
+Include config.d/*
+
+Host dev
+  IdentityFile ~/.ssh/missing
+  IdentityFile %d/.ssh/id_ed25519
+  IdentityFile ~/.ssh/%h
+  IdentityFile relative
+  IdentityFile none
+`,
+		".ssh/config.d/work": "Host work\n  IdentityFile /etc/ssh/keys/work\n",
+		".ssh/id_ed25519":    "key\n",
+	})
+
+	tree, err := LoadFile(".ssh/config", LoadOptions{FS: fsys})
+	assert.NoError(t, err)
+
+	var reported []string
+	for _, finding := range tree.CheckIdentityFiles() {
+		assert.Equal(t, RuleMissingIdentityFile, finding.Rule)
+		reported = append(reported, finding.String())
+	}
+	assert.Equal(t, []string{
+		"Host dev: IdentityFile: ~/.ssh/missing does not exist (missing-identity-file)",
+		"Host work: IdentityFile: /etc/ssh/keys/work does not exist (missing-identity-file)",
+	}, reported)
+
+	// the local filesystem is used without an FS
+	home := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(home, ".ssh", "config"), []byte("IdentityFile ~/.ssh/gone\n"), 0600))
+
+	tree, err = LoadFile(filepath.Join(home, ".ssh", "config"), LoadOptions{HomeDir: home})
+	assert.NoError(t, err)
+	assert.Len(t, tree.CheckIdentityFiles(), 1)
+}
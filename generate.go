@@ -0,0 +1,456 @@
+package sshconfig
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Errors returned when reading inventories and generating hosts
+var (
+	ErrUnknownFormat  = errors.New("sshconfig: unknown inventory format")
+	ErrUnknownKeyword = errors.New("sshconfig: unknown keyword")
+	ErrInvalidValue   = errors.New("sshconfig: invalid value")
+	ErrInvalidHeader  = errors.New("sshconfig: template must start with a Host or Match line")
+)
+
+// InventoryFormat is the encoding of an inventory file
+type InventoryFormat string
+
+// Supported inventory formats
+const (
+	InventoryJSON InventoryFormat = "json"
+	InventoryYAML InventoryFormat = "yaml"
+	InventoryCSV  InventoryFormat = "csv"
+)
+
+// GroupField is the record field naming the group of a host
+const GroupField = "group"
+
+// Inventory is a list of host records to generate Host blocks from
+// Records are flat maps of field names to values. A record takes the
+// values it does not set from the defaults of its group, then from the
+// defaults of every group
+//
+// In JSON and YAML an inventory looks like
+//
+//	defaults:
+//	  user: admin
+//	groups:
+//	  web:
+//	    user: deploy
+//	hosts:
+//	  - name: web1
+//	    address: 10.0.0.1
+//	    group: web
+//
+// Numbers are written as they are and booleans as yes or no. A CSV
+// inventory only has hosts: its first row names the fields, and empty
+// cells are left unset so defaults apply
+type Inventory struct {
+	Defaults map[string]string            `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	Groups   map[string]map[string]string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Hosts    []map[string]string          `json:"hosts" yaml:"hosts"`
+}
+
+// inventoryDocument is an inventory as decoded, before values are
+// turned into strings
+type inventoryDocument struct {
+	Defaults map[string]interface{}            `json:"defaults" yaml:"defaults"`
+	Groups   map[string]map[string]interface{} `json:"groups" yaml:"groups"`
+	Hosts    []map[string]interface{}          `json:"hosts" yaml:"hosts"`
+}
+
+// LoadInventory reads an inventory file, choosing the format from its
+// extension: .json, .yaml, .yml or .csv
+func LoadInventory(path string) (*Inventory, error) {
+
+	var format InventoryFormat
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = InventoryJSON
+	case ".yaml", ".yml":
+		format = InventoryYAML
+	case ".csv":
+		format = InventoryCSV
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadInventory(f, format)
+
+}
+
+// ReadInventory decodes an inventory in the given format
+func ReadInventory(r io.Reader, format InventoryFormat) (*Inventory, error) {
+
+	if format == InventoryCSV {
+		return readInventoryCSV(r)
+	}
+
+	var doc inventoryDocument
+	switch format {
+	case InventoryJSON:
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+	case InventoryYAML:
+		if err := yaml.NewDecoder(r).Decode(&doc); err != nil && err != io.EOF {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	inventory := &Inventory{}
+	var err error
+	if inventory.Defaults, err = inventoryValues(doc.Defaults); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	for name, values := range doc.Groups {
+		group, err := inventoryValues(values)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+		if inventory.Groups == nil {
+			inventory.Groups = map[string]map[string]string{}
+		}
+		inventory.Groups[name] = group
+	}
+	for i, values := range doc.Hosts {
+		record, err := inventoryValues(values)
+		if err != nil {
+			return nil, fmt.Errorf("host %d: %w", i+1, err)
+		}
+		inventory.Hosts = append(inventory.Hosts, record)
+	}
+
+	return inventory, nil
+
+}
+
+func readInventoryCSV(r io.Reader) (*Inventory, error) {
+
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := &Inventory{}
+	if len(rows) == 0 {
+		return inventory, nil
+	}
+
+	fields := rows[0]
+	for _, row := range rows[1:] {
+		record := map[string]string{}
+		for i, value := range row {
+			if value = strings.TrimSpace(value); value != "" {
+				record[strings.TrimSpace(fields[i])] = value
+			}
+		}
+		inventory.Hosts = append(inventory.Hosts, record)
+	}
+
+	return inventory, nil
+
+}
+
+// inventoryValues turns decoded values into strings
+func inventoryValues(values map[string]interface{}) (map[string]string, error) {
+
+	if values == nil {
+		return nil, nil
+	}
+
+	record := make(map[string]string, len(values))
+	for field, value := range values {
+		switch v := value.(type) {
+		case nil:
+			continue
+		case string:
+			record[field] = v
+		case bool:
+			record[field] = formatFlag(v)
+		case json.Number:
+			record[field] = v.String()
+		case int, int64, uint64, float64:
+			record[field] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("%w: field %s is not a single value", ErrInvalidValue, field)
+		}
+	}
+
+	return record, nil
+
+}
+
+func formatFlag(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// HostTemplate generates a Host block for each record of an inventory
+// It is written like a block of a config file, with text/template
+// actions in the patterns and arguments:
+//
+//	# {{.description}}
+//	Host {{.name}} {{.name}}.example.com
+//	  HostName {{.address}}
+//	  User {{.user}}
+//
+// Each line is executed with the fields of the record, where unset
+// fields are empty. A parameter whose arguments come out empty is left
+// out, so optional fields need no conditionals, and so is a comment
+// that comes out empty
+type HostTemplate struct {
+	match    bool
+	comments []*template.Template
+	patterns *template.Template
+	params   []paramTemplate
+}
+
+type paramTemplate struct {
+	comments []*template.Template
+	info     KeywordInfo
+	args     *template.Template
+}
+
+// ParseHostTemplate parses a host template
+// Keywords must be known to the keyword registry; they are written with
+// their canonical spelling
+func ParseHostTemplate(text string) (*HostTemplate, error) {
+
+	tmpl := &HostTemplate{}
+	var comments []*template.Template
+
+	parse := func(lineNumber int, line string) (*template.Template, error) {
+		t, err := template.New(fmt.Sprintf("line %d", lineNumber)).Option("missingkey=zero").Parse(line)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if line[0] == '#' {
+			t, err := parse(i+1, line)
+			if err != nil {
+				return nil, err
+			}
+			comments = append(comments, t)
+			continue
+		}
+
+		keyword, args := splitKeyword(line)
+
+		if tmpl.patterns == nil {
+			if !strings.EqualFold(keyword, HostKeyword) && !strings.EqualFold(keyword, MatchKeyword) {
+				return nil, fmt.Errorf("%w: line %d", ErrInvalidHeader, i+1)
+			}
+			t, err := parse(i+1, args)
+			if err != nil {
+				return nil, err
+			}
+			tmpl.match = strings.EqualFold(keyword, MatchKeyword)
+			tmpl.comments, tmpl.patterns, comments = comments, t, nil
+			continue
+		}
+
+		info, ok := LookupKeyword(keyword)
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d: %s", ErrUnknownKeyword, i+1, keyword)
+		}
+		t, err := parse(i+1, args)
+		if err != nil {
+			return nil, err
+		}
+		tmpl.params = append(tmpl.params, paramTemplate{comments: comments, info: info, args: t})
+		comments = nil
+
+	}
+
+	if tmpl.patterns == nil {
+		return nil, ErrInvalidHeader
+	}
+
+	return tmpl, nil
+
+}
+
+// Generate executes the template for every record of the inventory, in
+// order, and returns the blocks
+// Arguments are checked against the type of their keyword, so a Port
+// must be a number and a flag yes or no; every problem is reported,
+// naming the record it was found in
+func (tmpl *HostTemplate) Generate(inventory *Inventory) ([]*Host, error) {
+
+	var hosts []*Host
+	var errs []error
+
+	for i, record := range inventory.Hosts {
+		host, err := tmpl.Execute(inventory.values(record))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("host %d: %w", i+1, err))
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+
+}
+
+// values returns the fields of a record along with the defaults it takes
+func (inventory *Inventory) values(record map[string]string) map[string]string {
+
+	values := map[string]string{}
+	for field, value := range inventory.Defaults {
+		values[field] = value
+	}
+	for field, value := range inventory.Groups[record[GroupField]] {
+		values[field] = value
+	}
+	for field, value := range record {
+		values[field] = value
+	}
+
+	return values
+
+}
+
+// Execute generates a single block from the given fields
+func (tmpl *HostTemplate) Execute(values map[string]string) (*Host, error) {
+
+	var errs []error
+
+	patterns, err := execute(tmpl.patterns, values)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("%w: no patterns", ErrInvalidPattern)
+	}
+
+	comments, err := executeComments(tmpl.comments, values)
+	if err != nil {
+		return nil, err
+	}
+
+	host := NewHost(patterns, comments)
+	if tmpl.match {
+		host = NewMatch(patterns, comments)
+	}
+
+	for _, param := range tmpl.params {
+		args, err := execute(param.args, values)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if err := checkArgs(param.info, args); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		comments, err := executeComments(param.comments, values)
+		if err != nil {
+			return nil, err
+		}
+		host.AddParam(NewParam(param.info.Name, args, comments))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return host, nil
+
+}
+
+// execute runs a line of a template and splits the result into
+// arguments, like Parse does
+func execute(t *template.Template, values map[string]string) ([]string, error) {
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, values); err != nil {
+		return nil, err
+	}
+	return strings.Fields(buf.String()), nil
+}
+
+// executeComments runs the comment lines of a template, dropping those
+// left without text
+func executeComments(templates []*template.Template, values map[string]string) ([]string, error) {
+
+	var comments []string
+	for _, t := range templates {
+		buf := &bytes.Buffer{}
+		if err := t.Execute(buf, values); err != nil {
+			return nil, err
+		}
+		if comment := strings.TrimSpace(buf.String()); strings.TrimLeft(comment, "# ") != "" {
+			comments = append(comments, comment)
+		}
+	}
+
+	return comments, nil
+
+}
+
+// checkArgs reports arguments that ssh would reject for the keyword
+func checkArgs(info KeywordInfo, args []string) error {
+
+	if info.Removed {
+		return fmt.Errorf("%w: %s is no longer supported by OpenSSH", ErrInvalidValue, info.Name)
+	}
+
+	var err error
+	switch info.Type {
+	case TypeFlag:
+		_, err = parseFlag(args[0])
+	case TypeInt:
+		_, err = strconv.ParseInt(args[0], 10, 64)
+	case TypeDuration:
+		_, err = parseDuration(info, args[0])
+	}
+	if err == nil && info.Type != TypeString && len(args) > 1 {
+		err = fmt.Errorf("%q is more than one value", strings.Join(args, " "))
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidValue, info.Name, err)
+	}
+
+	return nil
+
+}
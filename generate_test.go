@@ -0,0 +1,176 @@
+package sshconfig
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var hostTemplateTest = `
+# {{.description}}
+Host {{.name}} {{.name}}.example.com
+  HostName {{.address}}
+  User {{.user}}
+  Port {{.port}}
+  # jump through the bastion
+  ProxyJump {{.bastion}}
+  ForwardAgent {{.agent}}
+`
+
+var inventoryYAMLTest = `
+defaults:
+  user: admin
+  agent: false
+groups:
+  web:
+    user: deploy
+    bastion: bastion.example.com
+hosts:
+  - name: web1
+    address: 10.0.0.1
+    group: web
+    description: first web server
+  - name: db1
+    address: 10.0.1.1
+    port: 5022
+    agent: true
+`
+
+var generatedHostsTest = `
+# first web server
+Host web1 web1.example.com
+  HostName 10.0.0.1
+  User deploy
+  # jump through the bastion
+  ProxyJump bastion.example.com
+  ForwardAgent no
+
+Host db1 db1.example.com
+  HostName 10.0.1.1
+  User admin
+  Port 5022
+  ForwardAgent yes
+`
+
+func generateString(t *testing.T, inventory *Inventory) string {
+
+	tmpl, err := ParseHostTemplate(hostTemplateTest)
+	assert.NoError(t, err)
+
+	hosts, err := tmpl.Generate(inventory)
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	for _, host := range hosts {
+		buf.WriteString(host.String())
+	}
+	return buf.String()
+}
+
+func TestGenerate(t *testing.T) {
+
+	inventory, err := ReadInventory(strings.NewReader(inventoryYAMLTest), InventoryYAML)
+	assert.NoError(t, err)
+
+	first := generateString(t, inventory)
+	assert.Equal(t, generatedHostsTest, first)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, generateString(t, inventory))
+	}
+}
+
+func TestReadInventory(t *testing.T) {
+
+	yamlInventory, err := ReadInventory(strings.NewReader(inventoryYAMLTest), InventoryYAML)
+	assert.NoError(t, err)
+
+	jsonInventory, err := ReadInventory(strings.NewReader(`{
+  "defaults": {"user": "admin", "agent": false},
+  "groups": {"web": {"user": "deploy", "bastion": "bastion.example.com"}},
+  "hosts": [
+    {"name": "web1", "address": "10.0.0.1", "group": "web", "description": "first web server"},
+    {"name": "db1", "address": "10.0.1.1", "port": 5022, "agent": true}
+  ]
+}`), InventoryJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, yamlInventory, jsonInventory)
+
+	csvInventory, err := ReadInventory(strings.NewReader(`name,address,group,port,user
+web1,10.0.0.1,web,,
+db1,10.0.1.1,,5022,admin
+`), InventoryCSV)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"name": "web1", "address": "10.0.0.1", "group": "web"},
+		{"name": "db1", "address": "10.0.1.1", "port": "5022", "user": "admin"},
+	}, csvInventory.Hosts)
+
+	_, err = ReadInventory(strings.NewReader(`{"hosts": [{"name": ["a", "b"]}]}`), InventoryJSON)
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	_, err = ReadInventory(strings.NewReader(""), "toml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestLoadInventory(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "hosts.csv")
+	assert.NoError(t, os.WriteFile(path, []byte("name,address\nweb1,10.0.0.1\n"), 0600))
+
+	inventory, err := LoadInventory(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"name": "web1", "address": "10.0.0.1"}}, inventory.Hosts)
+
+	_, err = LoadInventory("hosts.ini")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestParseHostTemplateErrors(t *testing.T) {
+
+	_, err := ParseHostTemplate("User {{.user}}\n")
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+
+	_, err = ParseHostTemplate("Host {{.name}}\n  Usr {{.user}}\n")
+	assert.ErrorIs(t, err, ErrUnknownKeyword)
+
+	_, err = ParseHostTemplate("Host {{.name}\n")
+	assert.Error(t, err)
+}
+
+func TestGenerateValidation(t *testing.T) {
+
+	tmpl, err := ParseHostTemplate("Match host {{.name}}\n  port {{.port}}\n  compression {{.compression}}\n")
+	assert.NoError(t, err)
+
+	hosts, err := tmpl.Generate(&Inventory{Hosts: []map[string]string{{"name": "web1", "port": "22"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "\nMatch host web1\n  Port 22\n", hosts[0].String())
+
+	_, err = tmpl.Generate(&Inventory{Hosts: []map[string]string{
+		{"name": "web1", "port": "ssh"},
+		{"name": "web2", "compression": "maybe"},
+		{"name": "web3"},
+	}})
+	assert.ErrorIs(t, err, ErrInvalidValue)
+	assert.Contains(t, err.Error(), "host 1: sshconfig: invalid value: Port")
+	assert.Contains(t, err.Error(), "host 2: sshconfig: invalid value: Compression")
+
+	tmpl, err = ParseHostTemplate("Host {{.name}}\n")
+	assert.NoError(t, err)
+	_, err = tmpl.Generate(&Inventory{Hosts: []map[string]string{{"port": "22"}}})
+	assert.ErrorIs(t, err, ErrInvalidPattern)
+}
+
+func TestParseHostTemplateSeparators(t *testing.T) {
+
+	tmpl, err := ParseHostTemplate("Host\t{{.name}}\n  Port={{.port}}\n  User = {{.user}}\n  HostName\t {{.name}}.example.com\n")
+	assert.NoError(t, err)
+
+	host, err := tmpl.Execute(map[string]string{"name": "web1", "port": "2222", "user": "deploy"})
+	assert.NoError(t, err)
+	assert.Equal(t, "\nHost web1\n  Port 2222\n  User deploy\n  HostName web1.example.com\n", host.String())
+}
@@ -0,0 +1,197 @@
+package sshconfig
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Errors returned by History
+var (
+	ErrNothingToUndo    = errors.New("sshconfig: nothing to undo")
+	ErrNothingToRedo    = errors.New("sshconfig: nothing to redo")
+	ErrNoSuchCheckpoint = errors.New("sshconfig: no such checkpoint")
+)
+
+// HistoryEntry is one reversible change recorded by a History
+type HistoryEntry struct {
+	// Description names the operation, such as "SetParam User deploy"
+	Description string
+	// Changes lists what the operation changed
+	Changes *ConfigDiff
+}
+
+func (entry HistoryEntry) String() string {
+	return entry.Description + "\n" + indentLines(entry.Changes.String(), "  ")
+}
+
+// History records the edits made to a config so they can be undone
+// Every editing method of Config is recorded under its own name.
+// Edits made any other way, such as the editing methods of Host or
+// changes to the exported slices, are detected by comparing the config
+// with its last recorded state before the next recorded edit, Undo,
+// Redo or Checkpoint, and are recorded as a single "direct edit".
+// Undo and Redo replace the hosts and parameters of the config, so
+// pointers to them obtained earlier no longer belong to it
+type History struct {
+	config *Config
+	// states[0] is the config when the history started and entries[i]
+	// turns states[i] into states[i+1]
+	states      []*Config
+	entries     []HistoryEntry
+	current     int
+	checkpoints map[string]int
+}
+
+// directEdit describes changes found by comparing states
+const directEdit = "direct edit"
+
+// History returns the history of the config, starting it on the first call
+func (config *Config) History() *History {
+	if config.history == nil {
+		config.history = &History{
+			config:      config,
+			states:      []*Config{config.Clone()},
+			checkpoints: map[string]int{},
+		}
+	}
+	return config.history
+}
+
+// edit runs a mutation of the config and records it in the history
+// fn must leave the config untouched when it fails
+func (config *Config) edit(description string, fn func() error) error {
+
+	if config.history == nil {
+		return fn()
+	}
+
+	config.history.sync()
+	if err := fn(); err != nil {
+		return err
+	}
+	config.history.record(description)
+
+	return nil
+
+}
+
+// sync records the changes made since the last recorded state
+func (history *History) sync() {
+	history.record(directEdit)
+}
+
+// record adds an entry when the config differs from its last recorded
+// state, dropping the changes that could have been redone
+func (history *History) record(description string) {
+
+	last := history.states[history.current]
+	if history.config.Equal(last) {
+		return
+	}
+
+	history.states = append(history.states[:history.current+1], history.config.Clone())
+	history.entries = append(history.entries[:history.current], HistoryEntry{
+		Description: description,
+		Changes:     Diff(last, history.config),
+	})
+	history.current++
+
+	for name, at := range history.checkpoints {
+		if at >= history.current {
+			delete(history.checkpoints, name)
+		}
+	}
+
+}
+
+// restore makes the config match the recorded state at index i
+func (history *History) restore(i int) {
+	state := history.states[i].Clone()
+	history.config.Globals, history.config.Hosts = state.Globals, state.Hosts
+	history.current = i
+}
+
+// Undo reverts the last recorded change
+func (history *History) Undo() error {
+	history.sync()
+	if history.current == 0 {
+		return ErrNothingToUndo
+	}
+	history.restore(history.current - 1)
+	return nil
+}
+
+// Redo applies the last undone change again
+// Changes can no longer be redone once a new change is recorded
+func (history *History) Redo() error {
+	history.sync()
+	if history.current == len(history.entries) {
+		return ErrNothingToRedo
+	}
+	history.restore(history.current + 1)
+	return nil
+}
+
+// Checkpoint names the current state of the config, replacing any
+// checkpoint with the same name
+func (history *History) Checkpoint(name string) {
+	history.sync()
+	history.checkpoints[name] = history.current
+}
+
+// RestoreCheckpoint undoes or redoes changes until the config is back
+// at the named checkpoint
+func (history *History) RestoreCheckpoint(name string) error {
+	history.sync()
+	at, ok := history.checkpoints[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoSuchCheckpoint, name)
+	}
+	history.restore(at)
+	return nil
+}
+
+// Log returns the changes that are currently applied, oldest first
+func (history *History) Log() []HistoryEntry {
+	history.sync()
+	return append([]HistoryEntry(nil), history.entries[:history.current]...)
+}
+
+// String returns a change log of the applied changes, with checkpoints
+// shown where they were taken
+func (history *History) String() string {
+
+	entries := history.Log()
+	labels := map[int][]string{}
+	for name, at := range history.checkpoints {
+		if at <= history.current {
+			labels[at] = append(labels[at], name)
+		}
+	}
+
+	buf := &strings.Builder{}
+	for i := 0; i <= len(entries); i++ {
+		sort.Strings(labels[i])
+		for _, name := range labels[i] {
+			fmt.Fprintf(buf, "-- checkpoint %s\n", name)
+		}
+		if i < len(entries) {
+			fmt.Fprintf(buf, "%d. %s", i+1, entries[i])
+		}
+	}
+
+	return buf.String()
+
+}
+
+func indentLines(s, indent string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "")
+}
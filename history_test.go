@@ -0,0 +1,106 @@
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var historyConfigTest = `VisualHostKey yes
+
+Host dev
+  User ubuntu
+`
+
+func TestHistoryUndoRedo(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(historyConfigTest))
+	assert.NoError(t, err)
+	history := config.History()
+
+	assert.ErrorIs(t, history.Undo(), ErrNothingToUndo)
+
+	config.SetParam(VisualHostKeyKeyword, "no")
+	config.AddHost(NewHost([]string{"prod"}, nil))
+	assert.NoError(t, config.MoveHost(config.Hosts[1], 0))
+	assert.ErrorIs(t, config.RemoveParams(BatchModeKeyword), ErrNoSuchParam)
+	config.SetParam(VisualHostKeyKeyword, "no")
+
+	var descriptions []string
+	for _, entry := range history.Log() {
+		descriptions = append(descriptions, entry.Description)
+	}
+	assert.Equal(t, []string{"SetParam VisualHostKey no", "AddHost Host prod", "MoveHost Host prod to 0"}, descriptions)
+
+	assert.NoError(t, history.Undo())
+	assert.Equal(t, []string{"Host dev", "Host prod"}, mutateHeaders(config))
+	assert.NoError(t, history.Undo())
+	assert.NoError(t, history.Undo())
+	assert.Equal(t, "yes", config.GetParam(VisualHostKeyKeyword).Value())
+	assert.ErrorIs(t, history.Undo(), ErrNothingToUndo)
+
+	assert.NoError(t, history.Redo())
+	assert.Equal(t, "no", config.GetParam(VisualHostKeyKeyword).Value())
+	assert.Equal(t, []string{"Host dev"}, mutateHeaders(config))
+
+	// a new change drops the changes that could be redone
+	assert.NoError(t, config.RemoveHost(config.Hosts[0]))
+	assert.ErrorIs(t, history.Redo(), ErrNothingToRedo)
+	assert.Len(t, history.Log(), 2)
+}
+
+func TestHistoryDirectEdits(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(historyConfigTest))
+	assert.NoError(t, err)
+	history := config.History()
+
+	config.Hosts[0].SetParam(UserKeyword, "root")
+	config.Globals = append(config.Globals, NewParam(BatchModeKeyword, []string{"yes"}, nil))
+	config.SetParam(VisualHostKeyKeyword, "no")
+
+	log := history.Log()
+	assert.Len(t, log, 2)
+	assert.Equal(t, directEdit, log[0].Description)
+
+	assert.NoError(t, history.Undo())
+	assert.NoError(t, history.Undo())
+	assert.Equal(t, "ubuntu", config.Hosts[0].GetParam(UserKeyword).Value())
+	assert.Nil(t, config.GetParam(BatchModeKeyword))
+}
+
+func TestHistoryCheckpoints(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(historyConfigTest))
+	assert.NoError(t, err)
+	history := config.History()
+
+	history.Checkpoint("start")
+	config.SetParam(VisualHostKeyKeyword, "no")
+	history.Checkpoint("quiet")
+	_, err = config.Set("host[dev].User", "deploy")
+	assert.NoError(t, err)
+
+	assert.Equal(t, `-- checkpoint start
+1. SetParam VisualHostKey no
+  ~ global configuration
+      ~ VisualHostKey yes -> no
+-- checkpoint quiet
+2. Set host[dev].User deploy
+  ~ Host dev
+      ~ User ubuntu -> deploy
+`, history.String())
+
+	assert.NoError(t, history.RestoreCheckpoint("start"))
+	assert.Equal(t, "yes", config.GetParam(VisualHostKeyKeyword).Value())
+	assert.NoError(t, history.RestoreCheckpoint("quiet"))
+	assert.Equal(t, "no", config.GetParam(VisualHostKeyKeyword).Value())
+	assert.Equal(t, "ubuntu", config.Hosts[0].GetParam(UserKeyword).Value())
+	assert.NoError(t, history.Redo())
+	assert.Equal(t, "deploy", config.Hosts[0].GetParam(UserKeyword).Value())
+
+	assert.NoError(t, history.RestoreCheckpoint("start"))
+	config.AddParam(NewParam(BatchModeKeyword, []string{"yes"}, nil))
+	assert.ErrorIs(t, history.RestoreCheckpoint("quiet"), ErrNoSuchCheckpoint)
+}
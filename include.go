@@ -0,0 +1,505 @@
+package sshconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxIncludeDepth is how deeply ssh lets Include directives nest
+const maxIncludeDepth = 16
+
+// Errors returned when loading or saving an IncludeTree
+var (
+	ErrIncludeDepth = errors.New("sshconfig: Include nested too deeply")
+	ErrNoSuchFile   = errors.New("sshconfig: file is not part of the tree")
+	ErrSystemFile   = errors.New("sshconfig: refusing to write a system file")
+)
+
+// systemConfigDirs hold the system-wide ssh configuration
+var systemConfigDirs = []string{"/etc/ssh"}
+
+// ParseFile parses the config file at path, recording the path in the
+// position of every node
+func ParseFile(path string) (*Config, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	config.setFile(path)
+
+	return config, nil
+
+}
+
+// ParseFS parses the named config file in fsys, recording the name in
+// the position of every node
+func ParseFS(fsys fs.FS, name string) (*Config, error) {
+
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	config.setFile(name)
+
+	return config, nil
+
+}
+
+// setFile records path as the file of every node
+func (config *Config) setFile(path string) {
+	for _, param := range config.Globals {
+		param.Pos.File = path
+	}
+	for _, host := range config.Hosts {
+		host.Pos.File = path
+		for _, param := range host.Params {
+			param.Pos.File = path
+		}
+	}
+}
+
+// ConfigFile is one file of an IncludeTree
+type ConfigFile struct {
+	Path   string
+	Config *Config
+
+	// saved is a copy of the config as it was last read or written
+	saved *Config
+}
+
+// Changed reports whether the config was edited since it was loaded or saved
+func (file *ConfigFile) Changed() bool {
+	return !file.Config.Equal(file.saved)
+}
+
+// IncludeTree is a config file along with every file it includes,
+// directly or not
+// Each file is kept as its own Config, so edits are saved to the file
+// the edited node came from; the position of every node records its
+// file. Use Config for a merged view of the tree as ssh reads it
+type IncludeTree struct {
+	// Root is the path of the file the tree was loaded from
+	Root string
+	// Files lists every loaded file, in the order they were first read
+	Files []*ConfigFile
+
+	// includes lists the files each Include directive matched
+	includes map[*Param][]string
+	// homeDir and baseDir are used to expand Include paths
+	homeDir string
+	baseDir string
+	// fsys holds the files, or is nil for the local filesystem
+	fsys fs.FS
+}
+
+// LoadOptions controls how LoadFile finds included files
+type LoadOptions struct {
+	// HomeDir replaces ~ in Include paths; it defaults to the home
+	// directory of the current user
+	HomeDir string
+	// BaseDir is where relative Include paths are looked up; it defaults
+	// to the directory of the root file, which ssh uses for ~/.ssh/config
+	BaseDir string
+	// FS is read instead of the local filesystem when set. Paths are
+	// then slash-separated names within it: absolute Include paths have
+	// their leading slash removed, and HomeDir defaults to the root of
+	// FS. The tree is saved to FS when it is a WritableFS
+	FS fs.FS
+}
+
+// LoadFile parses the config at path and every file it includes
+// Include paths are expanded like ssh does: ~ is the home directory,
+// relative paths are relative to BaseDir and wildcards match files in
+// lexical order. Included files that do not exist are skipped
+func LoadFile(filePath string, opts LoadOptions) (*IncludeTree, error) {
+
+	tree := &IncludeTree{
+		Root:     filePath,
+		includes: map[*Param][]string{},
+		homeDir:  opts.HomeDir,
+		baseDir:  opts.BaseDir,
+		fsys:     opts.FS,
+	}
+	if tree.fsys != nil {
+		if tree.homeDir == "" {
+			tree.homeDir = "."
+		}
+		if tree.baseDir == "" {
+			tree.baseDir = path.Dir(filePath)
+		}
+	}
+	if tree.homeDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		tree.homeDir = home
+	}
+	if tree.baseDir == "" {
+		tree.baseDir = filepath.Dir(filePath)
+	}
+
+	if err := tree.load(filePath, 0); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+
+}
+
+func (tree *IncludeTree) load(filePath string, depth int) error {
+
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%w: %s", ErrIncludeDepth, filePath)
+	}
+	if tree.File(filePath) != nil {
+		return nil
+	}
+
+	var config *Config
+	var err error
+	if tree.fsys != nil {
+		config, err = ParseFS(tree.fsys, filePath)
+	} else {
+		config, err = ParseFile(filePath)
+	}
+	if err != nil {
+		return err
+	}
+	tree.Files = append(tree.Files, &ConfigFile{Path: filePath, Config: config, saved: config.Clone()})
+
+	for _, param := range tree.Files[len(tree.Files)-1].params() {
+		if !strings.EqualFold(param.Keyword, IncludeKeyword) {
+			continue
+		}
+		paths, err := tree.expandInclude(param.Args)
+		if err != nil {
+			return err
+		}
+		tree.includes[param] = paths
+		for _, included := range paths {
+			if err := tree.load(included, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+
+}
+
+// expandInclude returns the files matched by the arguments of an Include
+func (tree *IncludeTree) expandInclude(patterns []string) ([]string, error) {
+
+	var paths []string
+
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, `"`)
+		if tree.fsys != nil {
+			pattern = tree.fsPattern(pattern)
+		} else if pattern == "~" || strings.HasPrefix(pattern, "~/") {
+			pattern = filepath.Join(tree.homeDir, pattern[1:])
+		} else if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(tree.baseDir, pattern)
+		}
+		matches, err := tree.glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if info, err := tree.stat(match); err == nil && !info.IsDir() {
+				paths = append(paths, match)
+			}
+		}
+	}
+
+	return paths, nil
+
+}
+
+// fsPattern turns an Include pattern into a name within the tree's FS
+func (tree *IncludeTree) fsPattern(pattern string) string {
+	switch {
+	case pattern == "~" || strings.HasPrefix(pattern, "~/"):
+		return path.Join(tree.homeDir, pattern[1:])
+	case path.IsAbs(pattern):
+		return path.Clean(strings.TrimLeft(pattern, "/"))
+	default:
+		return path.Join(tree.baseDir, pattern)
+	}
+}
+
+func (tree *IncludeTree) glob(pattern string) ([]string, error) {
+	if tree.fsys != nil {
+		return fs.Glob(tree.fsys, pattern)
+	}
+	return filepath.Glob(pattern)
+}
+
+func (tree *IncludeTree) stat(name string) (fs.FileInfo, error) {
+	if tree.fsys != nil {
+		return fs.Stat(tree.fsys, name)
+	}
+	return os.Stat(name)
+}
+
+// params returns every parameter of the file, in order
+func (file *ConfigFile) params() []*Param {
+	params := file.Config.Globals
+	for _, host := range file.Config.Hosts {
+		params = append(params[:len(params):len(params)], host.Params...)
+	}
+	return params
+}
+
+// File returns the loaded file at path, or nil
+func (tree *IncludeTree) File(path string) *ConfigFile {
+	for _, file := range tree.Files {
+		if file.Path == path {
+			return file
+		}
+	}
+	return nil
+}
+
+// AddHost appends a new block to the file at path, or to the root file
+// when path is empty
+func (tree *IncludeTree) AddHost(host *Host, path string) error {
+
+	if path == "" {
+		path = tree.Root
+	}
+
+	file := tree.File(path)
+	if file == nil {
+		return fmt.Errorf("%w: %s", ErrNoSuchFile, path)
+	}
+	file.Config.AddHost(host)
+
+	return nil
+
+}
+
+// Save writes every file that changed since it was loaded or last saved,
+// each atomically and with the checks of Config.Save, and returns the
+// paths it wrote
+// Files in the system configuration directory, such as
+// /etc/ssh/ssh_config, are refused unless opts.AllowSystemFiles is set,
+// before anything is written
+// A tree loaded from an FS is saved to it, or ErrReadOnlyFS is returned
+// when it is not a WritableFS
+func (tree *IncludeTree) Save(opts SaveOptions) ([]string, error) {
+
+	var changed []*ConfigFile
+	for _, file := range tree.Files {
+		if !file.Changed() {
+			continue
+		}
+		if tree.fsys == nil && !opts.AllowSystemFiles && isSystemFile(file.Path) {
+			return nil, fmt.Errorf("%w: %s", ErrSystemFile, file.Path)
+		}
+		changed = append(changed, file)
+	}
+
+	if tree.fsys != nil && len(changed) > 0 {
+		writable, ok := tree.fsys.(WritableFS)
+		if !ok {
+			return nil, ErrReadOnlyFS
+		}
+		opts.FS = writable
+	}
+
+	var written []string
+	for _, file := range changed {
+		if err := file.Config.Save(file.Path, opts); err != nil {
+			return written, err
+		}
+		file.saved = file.Config.Clone()
+		written = append(written, file.Path)
+	}
+
+	return written, nil
+
+}
+
+// Config returns the whole tree as a single config, with every Include
+// replaced by the contents of the files it matches, for use with
+// Resolve, Lint or Query
+// Parameters are shared with the files, so changing their arguments
+// edits the files, but blocks may be split where an Include appears
+// inside them; add and remove blocks through the files instead
+func (tree *IncludeTree) Config() *Config {
+
+	merged := &Config{}
+	tree.flatten(merged, tree.Root, nil, 0)
+
+	return merged
+
+}
+
+// flatten appends the nodes of the file at path to merged, in the block
+// current, and returns the block that is current at the end of the file
+func (tree *IncludeTree) flatten(merged *Config, path string, current *Host, depth int) *Host {
+
+	file := tree.File(path)
+	if file == nil || depth > maxIncludeDepth {
+		return current
+	}
+
+	add := func(param *Param) {
+		if _, loaded := tree.includes[param]; loaded {
+			current = tree.flattenInclude(merged, param, current, depth)
+			return
+		}
+		if current == nil {
+			merged.Globals = append(merged.Globals, param)
+			return
+		}
+		current.Params = append(current.Params, param)
+	}
+
+	for _, param := range file.Config.Globals {
+		add(param)
+	}
+
+	for _, host := range file.Config.Hosts {
+		current = &Host{
+			Comments:  host.Comments,
+			Hostnames: host.Hostnames,
+			Criteria:  host.Criteria,
+			Pos:       host.Pos,
+		}
+		merged.Hosts = append(merged.Hosts, current)
+		for _, param := range host.Params {
+			add(param)
+		}
+	}
+
+	return current
+
+}
+
+// flattenInclude appends the files matched by an Include and returns
+// the block that follows it
+// Like ssh, the lines after an Include belong to the block that held
+// it, so that block is continued when the included files opened others
+func (tree *IncludeTree) flattenInclude(merged *Config, include *Param, current *Host, depth int) *Host {
+
+	end := current
+	for _, path := range tree.includes[include] {
+		end = tree.flatten(merged, path, end, depth+1)
+	}
+	if end == current {
+		return current
+	}
+
+	resumed := NewMatch([]string{"all"}, nil)
+	if current != nil {
+		resumed = &Host{Hostnames: current.Hostnames, Criteria: current.Criteria, Pos: current.Pos}
+	}
+	merged.Hosts = append(merged.Hosts, resumed)
+
+	return resumed
+
+}
+
+// isSystemFile reports whether path is in the system configuration directory
+func isSystemFile(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, dir := range systemConfigDirs {
+		if rel, err := filepath.Rel(dir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckIdentityFiles reports the IdentityFile parameters of the tree
+// whose key does not exist, looking them up the way Include paths are:
+// in the tree's FS when it has one, with ~ and %d as the home directory
+// Paths with other % tokens or environment variables depend on the host
+// being connected to, and relative paths on the directory ssh runs in,
+// so they are not checked
+func (tree *IncludeTree) CheckIdentityFiles() []Finding {
+
+	var findings []Finding
+
+	check := func(host *Host, params []*Param) {
+		for _, param := range params {
+			if !strings.EqualFold(param.Keyword, IdentityFileKeyword) {
+				continue
+			}
+			name, ok := tree.identityPath(param.Value())
+			if !ok {
+				continue
+			}
+			if _, err := tree.stat(name); errors.Is(err, fs.ErrNotExist) {
+				findings = append(findings, Finding{
+					Rule:    RuleMissingIdentityFile,
+					Message: fmt.Sprintf("%s does not exist", param.Value()),
+					Host:    host,
+					Param:   param,
+				})
+			}
+		}
+	}
+
+	for _, file := range tree.Files {
+		check(nil, file.Config.Globals)
+		for _, host := range file.Config.Hosts {
+			check(host, host.Params)
+		}
+	}
+
+	return findings
+
+}
+
+// identityPath returns where the key named by an IdentityFile value is,
+// or false when it cannot be known without connecting
+func (tree *IncludeTree) identityPath(value string) (string, bool) {
+
+	value = strings.Trim(value, `"`)
+	if value == "" || strings.EqualFold(value, "none") {
+		return "", false
+	}
+	if value == "%d" || strings.HasPrefix(value, "%d/") {
+		value = "~" + value[2:]
+	}
+	if strings.Contains(value, "%") || strings.Contains(value, "${") {
+		return "", false
+	}
+
+	home := value == "~" || strings.HasPrefix(value, "~/")
+	switch {
+	case tree.fsys != nil && (home || path.IsAbs(value)):
+		return tree.fsPattern(value), true
+	case home:
+		return filepath.Join(tree.homeDir, value[1:]), true
+	case filepath.IsAbs(value):
+		return value, true
+	}
+
+	return "", false
+
+}
@@ -0,0 +1,132 @@
+package sshconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTree writes files relative to a temporary directory and returns it
+func writeTree(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	}
+	return dir
+}
+
+func TestLoadFile(t *testing.T) {
+
+	dir := writeTree(t, map[string]string{
+		"config": `Include config.d/*
+ForwardAgent no
+
+Host dev
+  Include ~/extra
+  User ubuntu
+`,
+		"config.d/personal": "Host home\n  User me\n",
+		"config.d/work":     "Host work\n  User employee\n",
+		"home/extra":        "Port 2222\nHost bastion\n  User jump\n",
+	})
+
+	tree, err := LoadFile(filepath.Join(dir, "config"), LoadOptions{HomeDir: filepath.Join(dir, "home")})
+	assert.NoError(t, err)
+
+	var paths []string
+	for _, file := range tree.Files {
+		rel, _ := filepath.Rel(dir, file.Path)
+		paths = append(paths, rel)
+	}
+	assert.Equal(t, []string{"config", "config.d/personal", "config.d/work", "home/extra"}, paths)
+	assert.Equal(t, filepath.Join(dir, "config.d", "work"), tree.Files[2].Config.Hosts[0].Params[0].Pos.File)
+
+	merged := tree.Config()
+	assert.Equal(t, []string{"Host home", "Host work", "Match all", "Host dev", "Host bastion", "Host dev"}, mutateHeaders(merged))
+
+	dev := merged.Resolve("dev")
+	assert.Equal(t, "2222", dev.GetParam(PortKeyword).Value())
+	assert.Equal(t, "ubuntu", dev.GetParam(UserKeyword).Value())
+	assert.Equal(t, "no", dev.GetParam(ForwardAgentKeyword).Value())
+	assert.Equal(t, filepath.Join(dir, "home", "extra")+":1", dev.GetParam(PortKeyword).Pos.String())
+	assert.Equal(t, "jump", merged.Resolve("bastion").GetParam(UserKeyword).Value())
+}
+
+func TestIncludeTreeSave(t *testing.T) {
+
+	dir := writeTree(t, map[string]string{
+		"config":            "Include config.d/*\n",
+		"config.d/personal": "Host home\n  User me\n",
+		"config.d/work":     "Host work\n  User employee\n",
+	})
+
+	tree, err := LoadFile(filepath.Join(dir, "config"), LoadOptions{HomeDir: dir})
+	assert.NoError(t, err)
+
+	written, err := tree.Save(SaveOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, written)
+
+	tree.File(filepath.Join(dir, "config.d", "work")).Config.GetHost("work").SetParam(UserKeyword, "contractor")
+	assert.NoError(t, tree.AddHost(NewHost([]string{"lab"}, nil), filepath.Join(dir, "config.d", "personal")))
+	assert.ErrorIs(t, tree.AddHost(NewHost([]string{"lab"}, nil), filepath.Join(dir, "elsewhere")), ErrNoSuchFile)
+
+	written, err = tree.Save(SaveOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "config.d", "personal"), filepath.Join(dir, "config.d", "work")}, written)
+
+	root, err := os.ReadFile(filepath.Join(dir, "config"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Include config.d/*\n", string(root))
+
+	reloaded, err := LoadFile(filepath.Join(dir, "config"), LoadOptions{HomeDir: dir})
+	assert.NoError(t, err)
+	assert.Equal(t, "contractor", reloaded.Config().Resolve("work").GetParam(UserKeyword).Value())
+	assert.NotNil(t, reloaded.Files[1].Config.GetHost("lab"))
+
+	written, err = tree.Save(SaveOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, written)
+}
+
+func TestIncludeTreeSystemFiles(t *testing.T) {
+
+	assert.True(t, isSystemFile("/etc/ssh/ssh_config"))
+	assert.True(t, isSystemFile("/etc/ssh/ssh_config.d/50-distro.conf"))
+	assert.False(t, isSystemFile("/etc/sshd"))
+	assert.False(t, isSystemFile(filepath.Join(t.TempDir(), "config")))
+
+	tree := &IncludeTree{Files: []*ConfigFile{{
+		Path:   "/etc/ssh/ssh_config",
+		Config: &Config{Globals: []*Param{NewParam(UserKeyword, []string{"root"}, nil)}},
+		saved:  &Config{},
+	}}}
+	_, err := tree.Save(SaveOptions{})
+	assert.ErrorIs(t, err, ErrSystemFile)
+	assert.ErrorIs(t, (&Config{}).Save("/etc/ssh/ssh_config", SaveOptions{}), ErrSystemFile)
+}
+
+func TestLoadFileDepth(t *testing.T) {
+
+	files := map[string]string{}
+	for i := 0; i <= maxIncludeDepth+1; i++ {
+		files[fmt.Sprintf("config%d", i)] = fmt.Sprintf("Include config%d\n", i+1)
+	}
+	dir := writeTree(t, files)
+
+	_, err := LoadFile(filepath.Join(dir, "config0"), LoadOptions{HomeDir: dir})
+	assert.ErrorIs(t, err, ErrIncludeDepth)
+
+	// a file including itself is read once
+	dir = writeTree(t, map[string]string{"config": "Include config\nHost dev\n"})
+	tree, err := LoadFile(filepath.Join(dir, "config"), LoadOptions{HomeDir: dir})
+	assert.NoError(t, err)
+	assert.Len(t, tree.Files, 1)
+	assert.True(t, strings.HasSuffix(tree.Files[0].Path, "config"))
+}
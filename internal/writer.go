@@ -9,6 +9,7 @@ import (
 type WriteCounter struct {
 	io.Writer
 	written int64
+	err     error
 }
 
 // NewWriteCounter returns a new instance of the WriteCounter.
@@ -21,12 +22,23 @@ func (w *WriteCounter) Written() int64 {
 	return w.written
 }
 
+// Err returns the first error returned by the underline writer.
+func (w *WriteCounter) Err() error {
+	return w.err
+}
+
 // Write calls the internal io.Writer.Write method and adds up
-// the write counts.
+// the write counts. Once a write has failed, later writes are
+// skipped and return the same error.
 func (w *WriteCounter) Write(data []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
 	inc, err := w.Writer.Write(data)
 
 	w.written += int64(inc)
+	w.err = err
 
 	return inc, err
 }
@@ -0,0 +1,268 @@
+package sshconfig
+
+import (
+	"strings"
+)
+
+// KeywordType is the kind of value ssh expects for a keyword
+type KeywordType int
+
+// Types of keyword values
+const (
+	// TypeString values are used as written
+	TypeString KeywordType = iota
+	// TypeFlag values are yes or no
+	TypeFlag
+	// TypeInt values are decimal numbers
+	TypeInt
+	// TypeDuration values use the ssh time format, such as 90 or 1h30m,
+	// where a bare number counts seconds
+	TypeDuration
+	// TypeList values are comma-separated lists
+	TypeList
+)
+
+// KeywordInfo describes how ssh interprets a configuration keyword
+type KeywordInfo struct {
+	// Name is the canonical spelling of the keyword
+	Name string
+	// Type is the kind of value the keyword takes
+	Type KeywordType
+	// Repeatable keywords accumulate every value instead of using the first one
+	Repeatable bool
+	// ReplacedBy names the keyword that supersedes a deprecated keyword
+	ReplacedBy string
+	// Removed keywords are no longer supported by OpenSSH
+	Removed bool
+	// Command keywords take the rest of the line as a shell command,
+	// so their arguments must be kept exactly as written
+	Command bool
+}
+
+var keywordRegistry = map[string]KeywordInfo{}
+
+func init() {
+
+	for _, name := range []string{
+		AddressFamilyKeyword,
+		BatchModeKeyword,
+		BindAddressKeyword,
+		BindInterfaceKeyword,
+		CanonicalDomainsKeyword,
+		CanonicalizeFallbackLocalKeyword,
+		CanonicalizeHostnameKeyword,
+		CanonicalizeMaxDotsKeyword,
+		CanonicalizePermittedCNAMEsKeyword,
+		CheckHostIPKeyword,
+		CiphersKeyword,
+		ClearAllForwardingsKeyword,
+		CompressionKeyword,
+		ConnectionAttemptsKeyword,
+		ConnectTimeoutKeyword,
+		ControlMasterKeyword,
+		ControlPathKeyword,
+		ControlPersistKeyword,
+		EnableSSHKeysignKeyword,
+		EscapeCharKeyword,
+		ExitOnForwardFailureKeyword,
+		FingerprintHashKeyword,
+		ForkAfterAuthenticationKeyword,
+		ForwardAgentKeyword,
+		ForwardX11Keyword,
+		ForwardX11TimeoutKeyword,
+		ForwardX11TrustedKeyword,
+		GatewayPortsKeyword,
+		GlobalKnownHostsFileKeyword,
+		GSSAPIAuthenticationKeyword,
+		GSSAPIDelegateCredentialsKeyword,
+		HashKnownHostsKeyword,
+		HostbasedAcceptedAlgorithmsKeyword,
+		HostbasedAuthenticationKeyword,
+		HostKeyAlgorithmsKeyword,
+		HostKeyAliasKeyword,
+		HostNameKeyword,
+		IdentitiesOnlyKeyword,
+		IgnoreUnknownKeyword,
+		IPQoSKeyword,
+		KbdInteractiveAuthenticationKeyword,
+		KbdInteractiveDevicesKeyword,
+		KexAlgorithmsKeyword,
+		LogLevelKeyword,
+		MACsKeyword,
+		NoHostAuthenticationForLocalhostKeyword,
+		NumberOfPasswordPromptsKeyword,
+		PasswordAuthenticationKeyword,
+		PermitLocalCommandKeyword,
+		PKCS11ProviderKeyword,
+		PortKeyword,
+		PreferredAuthenticationsKeyword,
+		ProxyJumpKeyword,
+		ProxyUseFdpassKeyword,
+		PubkeyAcceptedAlgorithmsKeyword,
+		PubkeyAuthenticationKeyword,
+		RekeyLimitKeyword,
+		RequestTTYKeyword,
+		RevokedHostKeysKeyword,
+		ServerAliveCountMaxKeyword,
+		ServerAliveIntervalKeyword,
+		SessionTypeKeyword,
+		StdinNullKeyword,
+		StreamLocalBindMaskKeyword,
+		StreamLocalBindUnlinkKeyword,
+		StrictHostKeyCheckingKeyword,
+		TCPKeepAliveKeyword,
+		TunnelKeyword,
+		TunnelDeviceKeyword,
+		UpdateHostKeysKeyword,
+		UserKeyword,
+		UserKnownHostsFileKeyword,
+		VerifyHostKeyDNSKeyword,
+		VisualHostKeyKeyword,
+		XAuthLocationKeyword,
+	} {
+		registerKeyword(KeywordInfo{Name: name})
+	}
+
+	for _, name := range []string{
+		CertificateFileKeyword,
+		DynamicForwardKeyword,
+		IdentityFileKeyword,
+		IncludeKeyword,
+		LocalForwardKeyword,
+		RemoteForwardKeyword,
+		SendEnvKeyword,
+		SetEnvKeyword,
+	} {
+		registerKeyword(KeywordInfo{Name: name, Repeatable: true})
+	}
+
+	for _, name := range []string{
+		KnownHostsCommandKeyword,
+		LocalCommandKeyword,
+		ProxyCommandKeyword,
+		RemoteCommandKeyword,
+	} {
+		registerKeyword(KeywordInfo{Name: name, Command: true})
+	}
+
+	registerKeyword(KeywordInfo{Name: ChallengeResponseAuthenticationKeyword, ReplacedBy: KbdInteractiveAuthenticationKeyword})
+	registerKeyword(KeywordInfo{Name: HostbasedKeyTypesKeyword, ReplacedBy: HostbasedAcceptedAlgorithmsKeyword})
+	registerKeyword(KeywordInfo{Name: PubkeyAcceptedKeyTypesKeyword, ReplacedBy: PubkeyAcceptedAlgorithmsKeyword})
+
+	for _, name := range []string{
+		CipherKeyword,
+		CompressionLevelKeyword,
+		ProtocolKeyword,
+		RhostsRSAAuthenticationKeyword,
+		RSAAuthenticationKeyword,
+		UsePrivilegedPortKeyword,
+		UseRoamingKeyword,
+	} {
+		registerKeyword(KeywordInfo{Name: name, Removed: true})
+	}
+
+	for keywordType, names := range map[KeywordType][]string{
+		TypeFlag: {
+			BatchModeKeyword,
+			CanonicalizeFallbackLocalKeyword,
+			ChallengeResponseAuthenticationKeyword,
+			CheckHostIPKeyword,
+			ClearAllForwardingsKeyword,
+			CompressionKeyword,
+			EnableSSHKeysignKeyword,
+			ExitOnForwardFailureKeyword,
+			ForkAfterAuthenticationKeyword,
+			ForwardX11Keyword,
+			ForwardX11TrustedKeyword,
+			GatewayPortsKeyword,
+			GSSAPIAuthenticationKeyword,
+			GSSAPIDelegateCredentialsKeyword,
+			HashKnownHostsKeyword,
+			HostbasedAuthenticationKeyword,
+			IdentitiesOnlyKeyword,
+			KbdInteractiveAuthenticationKeyword,
+			NoHostAuthenticationForLocalhostKeyword,
+			PasswordAuthenticationKeyword,
+			PermitLocalCommandKeyword,
+			ProxyUseFdpassKeyword,
+			StdinNullKeyword,
+			StreamLocalBindUnlinkKeyword,
+			TCPKeepAliveKeyword,
+			VisualHostKeyKeyword,
+		},
+		TypeInt: {
+			CanonicalizeMaxDotsKeyword,
+			ConnectionAttemptsKeyword,
+			NumberOfPasswordPromptsKeyword,
+			PortKeyword,
+			ServerAliveCountMaxKeyword,
+		},
+		TypeDuration: {
+			ConnectTimeoutKeyword,
+			ControlPersistKeyword,
+			ForwardX11TimeoutKeyword,
+			ServerAliveIntervalKeyword,
+		},
+		TypeList: {
+			CiphersKeyword,
+			HostbasedAcceptedAlgorithmsKeyword,
+			HostbasedKeyTypesKeyword,
+			HostKeyAlgorithmsKeyword,
+			KbdInteractiveDevicesKeyword,
+			KexAlgorithmsKeyword,
+			MACsKeyword,
+			PreferredAuthenticationsKeyword,
+			PubkeyAcceptedAlgorithmsKeyword,
+			PubkeyAcceptedKeyTypesKeyword,
+		},
+	} {
+		for _, name := range names {
+			info := keywordRegistry[strings.ToLower(name)]
+			info.Type = keywordType
+			registerKeyword(info)
+		}
+	}
+
+}
+
+func registerKeyword(info KeywordInfo) {
+	keywordRegistry[strings.ToLower(info.Name)] = info
+}
+
+// LookupKeyword returns the registry entry for a keyword
+// Keywords are matched case-insensitively, like ssh does
+func LookupKeyword(keyword string) (KeywordInfo, bool) {
+	info, ok := keywordRegistry[strings.ToLower(keyword)]
+	return info, ok
+}
+
+// canonicalKeyword returns the key ssh uses to decide whether two
+// keywords set the same option: case is ignored and deprecated
+// aliases map onto their replacement
+func canonicalKeyword(keyword string) string {
+	if info, ok := LookupKeyword(keyword); ok && info.ReplacedBy != "" {
+		return strings.ToLower(info.ReplacedBy)
+	}
+	return strings.ToLower(keyword)
+}
+
+// isRepeatable reports whether every occurrence of a keyword takes effect
+func isRepeatable(keyword string) bool {
+	info, ok := LookupKeyword(keyword)
+	return ok && info.Repeatable
+}
+
+// splitKeyword splits a config line into its keyword and arguments
+// Like ssh, the keyword ends at whitespace or an equals sign, and
+// whitespace around a single equals sign separates it from the
+// arguments, as in "Port=22" or "Port = 22"
+func splitKeyword(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	i := strings.IndexAny(line, "= \t")
+	if i < 0 {
+		return line, ""
+	}
+	args := strings.TrimLeft(line[i:], " \t")
+	args = strings.TrimPrefix(args, "=")
+	return line[:i], strings.TrimSpace(args)
+}
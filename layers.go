@@ -0,0 +1,167 @@
+package sshconfig
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the layers of a LayeredConfig
+const (
+	UserLayer   = "user"
+	SystemLayer = "system"
+)
+
+// defaultSystemConfig is where ssh reads the system-wide configuration
+const defaultSystemConfig = "/etc/ssh/ssh_config"
+
+// Layer is one config file of a LayeredConfig along with its includes
+type Layer struct {
+	// Name is UserLayer or SystemLayer
+	Name string
+	Tree *IncludeTree
+}
+
+// LayeredConfig is every config file ssh reads, in the order it reads
+// them: the user config, then the system config
+// Like ssh, the first value obtained for a keyword wins across all
+// layers, so the user config overrides the system config
+type LayeredConfig struct {
+	Layers []*Layer
+}
+
+// LayerOptions controls where LoadLayers looks for config files
+type LayerOptions struct {
+	// File is the config given to ssh with -F; when set, it is the only
+	// layer and the system config is not read, as with ssh
+	File string
+	// HomeDir is the home directory, holding .ssh/config; it defaults to
+	// the home directory of the current user, or to the root of FS
+	HomeDir string
+	// SystemConfig is the system config; it defaults to
+	// /etc/ssh/ssh_config. Relative Include paths in it, such as
+	// Debian's ssh_config.d/*.conf, are relative to its directory
+	SystemConfig string
+	// FS is read instead of the local filesystem when set, with absolute
+	// paths taken relative to its root, see LoadOptions
+	FS fs.FS
+}
+
+// LoadLayers loads the config files ssh would read, skipping the user
+// and system configs when they do not exist
+func LoadLayers(opts LayerOptions) (*LayeredConfig, error) {
+
+	home := opts.HomeDir
+	if home == "" && opts.FS != nil {
+		home = "."
+	}
+	if home == "" {
+		var err error
+		if home, err = os.UserHomeDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	join, dir := filepath.Join, filepath.Dir
+	if opts.FS != nil {
+		join, dir = path.Join, path.Dir
+	}
+	userDir := join(home, ".ssh")
+
+	stack := &LayeredConfig{}
+	load := func(name, file, baseDir string, optional bool) error {
+		if opts.FS != nil {
+			file = strings.TrimLeft(file, "/")
+		}
+		tree, err := LoadFile(file, LoadOptions{HomeDir: home, BaseDir: baseDir, FS: opts.FS})
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		stack.Layers = append(stack.Layers, &Layer{Name: name, Tree: tree})
+		return nil
+	}
+
+	if opts.File != "" {
+		if err := load(UserLayer, opts.File, userDir, false); err != nil {
+			return nil, err
+		}
+		return stack, nil
+	}
+
+	if err := load(UserLayer, join(userDir, "config"), userDir, true); err != nil {
+		return nil, err
+	}
+
+	system := opts.SystemConfig
+	if system == "" {
+		system = defaultSystemConfig
+	}
+	if opts.FS != nil {
+		system = strings.TrimLeft(system, "/")
+	}
+	if err := load(SystemLayer, system, dir(system), true); err != nil {
+		return nil, err
+	}
+
+	return stack, nil
+
+}
+
+// Layer returns the layer with the given name, or nil
+func (stack *LayeredConfig) Layer(name string) *Layer {
+	for _, layer := range stack.Layers {
+		if layer.Name == name {
+			return layer
+		}
+	}
+	return nil
+}
+
+// LayerOf returns the layer a node came from, found from the file in
+// its position, or nil
+// The parameters returned by Resolve keep their positions, so this
+// tells which layer each resolved value came from
+func (stack *LayeredConfig) LayerOf(pos Position) *Layer {
+	for _, layer := range stack.Layers {
+		if pos.File != "" && layer.Tree.File(pos.File) != nil {
+			return layer
+		}
+	}
+	return nil
+}
+
+// Config returns every layer as a single config, in the order ssh reads
+// them, for use with Resolve, Lint or Query; see IncludeTree.Config
+// The global parameters of a layer after the first are put in a
+// Match all block, as ssh applies them to every host even though they
+// come after the blocks of the earlier layers
+func (stack *LayeredConfig) Config() *Config {
+
+	merged := &Config{}
+	for _, layer := range stack.Layers {
+		config := layer.Tree.Config()
+		if len(merged.Hosts) > 0 && len(config.Globals) > 0 {
+			all := NewMatch([]string{"all"}, nil)
+			all.Params = config.Globals
+			merged.Hosts = append(merged.Hosts, all)
+		} else {
+			merged.Globals = append(merged.Globals, config.Globals...)
+		}
+		merged.Hosts = append(merged.Hosts, config.Hosts...)
+	}
+
+	return merged
+
+}
+
+// Resolve returns the parameters ssh would use when connecting to
+// hostname, across every layer
+func (stack *LayeredConfig) Resolve(hostname string) *Host {
+	return stack.Config().Resolve(hostname)
+}
@@ -0,0 +1,80 @@
+package sshconfig
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLayers(t *testing.T) {
+
+	fsys := NewMemFS(map[string]string{
+		"home/me/.ssh/config": `Include work
+Host dev
+  User ubuntu
+`,
+		"home/me/.ssh/work": "Host *.corp\n  User employee\n",
+		"etc/ssh/ssh_config": `Include /etc/ssh/ssh_config.d/*.conf
+
+Host *
+  SendEnv LANG LC_*
+  User nobody
+`,
+		"etc/ssh/ssh_config.d/20-crypto.conf": "Ciphers aes256-gcm@openssh.com\n",
+	})
+
+	stack, err := LoadLayers(LayerOptions{FS: fsys, HomeDir: "home/me"})
+	assert.NoError(t, err)
+	assert.Len(t, stack.Layers, 2)
+	assert.Equal(t, "home/me/.ssh/config", stack.Layer(UserLayer).Tree.Root)
+	assert.Len(t, stack.Layer(UserLayer).Tree.Files, 2)
+	assert.Len(t, stack.Layer(SystemLayer).Tree.Files, 2)
+
+	dev := stack.Resolve("dev")
+	assert.Equal(t, "ubuntu", dev.GetParam(UserKeyword).Value())
+	assert.Equal(t, "aes256-gcm@openssh.com", dev.GetParam(CiphersKeyword).Value())
+	assert.Equal(t, "employee", stack.Resolve("git.corp").GetParam(UserKeyword).Value())
+	assert.Equal(t, "nobody", stack.Resolve("other").GetParam(UserKeyword).Value())
+
+	// the system globals follow the user blocks, yet apply to every host
+	assert.Equal(t, []string{"Host *.corp", "Match all", "Host dev", "Match all", "Host *"}, mutateHeaders(stack.Config()))
+
+	assert.Equal(t, UserLayer, stack.LayerOf(dev.GetParam(UserKeyword).Pos).Name)
+	ciphers := dev.GetParam(CiphersKeyword).Pos
+	assert.Equal(t, SystemLayer, stack.LayerOf(ciphers).Name)
+	assert.Equal(t, "etc/ssh/ssh_config.d/20-crypto.conf:1", ciphers.String())
+	assert.Nil(t, stack.LayerOf(Position{}))
+}
+
+func TestLoadLayersCommandLine(t *testing.T) {
+
+	fsys := NewMemFS(map[string]string{
+		"project/ssh_config": "Include extra\nUser deploy\n",
+		".ssh/extra":         "Port 2222\n",
+		"etc/ssh/ssh_config": "User nobody\n",
+	})
+
+	stack, err := LoadLayers(LayerOptions{FS: fsys, File: "project/ssh_config"})
+	assert.NoError(t, err)
+	assert.Len(t, stack.Layers, 1)
+
+	host := stack.Resolve("dev")
+	assert.Equal(t, "deploy", host.GetParam(UserKeyword).Value())
+	assert.Equal(t, "2222", host.GetParam(PortKeyword).Value())
+
+	_, err = LoadLayers(LayerOptions{FS: fsys, File: "missing"})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestLoadLayersMissing(t *testing.T) {
+
+	dir := writeTree(t, map[string]string{"ssh_config": "User nobody\n"})
+
+	stack, err := LoadLayers(LayerOptions{HomeDir: dir, SystemConfig: filepath.Join(dir, "ssh_config")})
+	assert.NoError(t, err)
+	assert.Len(t, stack.Layers, 1)
+	assert.Nil(t, stack.Layer(UserLayer))
+	assert.Equal(t, "nobody", stack.Resolve("dev").GetParam(UserKeyword).Value())
+}
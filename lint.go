@@ -0,0 +1,218 @@
+package sshconfig
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Rules reported by Lint
+const (
+	RuleDeprecatedKeyword = "deprecated-keyword"
+	RuleRemovedKeyword    = "removed-keyword"
+	RuleDuplicateKeyword  = "duplicate-keyword"
+	RuleWildcardHostOrder = "wildcard-host-order"
+	RuleWeakAlgorithm     = "weak-algorithm"
+	// RuleMissingIdentityFile is reported by IncludeTree.CheckIdentityFiles
+	RuleMissingIdentityFile = "missing-identity-file"
+)
+
+// Finding is a problem reported by Lint
+// Host is nil for findings about global parameters and Param is nil
+// for findings about a whole host block
+type Finding struct {
+	Rule    string
+	Message string
+	Host    *Host
+	Param   *Param
+	// Fix is the edit that resolves the finding, if one is known
+	Fix *Fix
+}
+
+func (finding Finding) String() string {
+
+	location := "global"
+	if finding.Host != nil {
+		location = finding.Host.header()
+	}
+	if finding.Param != nil {
+		location += ": " + finding.Param.Keyword
+	}
+
+	return fmt.Sprintf("%s: %s (%s)", location, finding.Message, finding.Rule)
+
+}
+
+// weakAlgorithms lists algorithms that should no longer be offered,
+// keyed by the keyword that configures them
+var weakAlgorithms = map[string][]string{
+	CiphersKeyword: {
+		"3des-cbc", "aes128-cbc", "aes192-cbc", "aes256-cbc", "arcfour", "arcfour128",
+		"arcfour256", "blowfish-cbc", "cast128-cbc", "rijndael-cbc@lysator.liu.se",
+	},
+	MACsKeyword: {
+		"hmac-md5", "hmac-md5-96", "hmac-md5-etm@openssh.com", "hmac-md5-96-etm@openssh.com",
+		"hmac-sha1-96", "hmac-sha1-96-etm@openssh.com", "hmac-ripemd160", "hmac-ripemd160@openssh.com",
+	},
+	KexAlgorithmsKeyword: {
+		"diffie-hellman-group1-sha1", "diffie-hellman-group14-sha1", "diffie-hellman-group-exchange-sha1",
+	},
+	HostKeyAlgorithmsKeyword: {
+		"ssh-dss", "ssh-dss-cert-v01@openssh.com", "ssh-rsa", "ssh-rsa-cert-v01@openssh.com",
+	},
+	PubkeyAcceptedAlgorithmsKeyword: {
+		"ssh-dss", "ssh-dss-cert-v01@openssh.com", "ssh-rsa", "ssh-rsa-cert-v01@openssh.com",
+	},
+	HostbasedAcceptedAlgorithmsKeyword: {
+		"ssh-dss", "ssh-dss-cert-v01@openssh.com", "ssh-rsa", "ssh-rsa-cert-v01@openssh.com",
+	},
+}
+
+// Lint checks a config for common mistakes
+// Findings are returned in file order
+func (config *Config) Lint() []Finding {
+
+	var findings []Finding
+
+	findings = append(findings, lintParams(nil, config.Globals)...)
+
+	for _, host := range config.Hosts {
+		findings = append(findings, lintParams(host, host.Params)...)
+	}
+
+	for i, host := range config.Hosts {
+		if len(host.Hostnames) == 1 && host.Hostnames[0] == "*" && i < len(config.Hosts)-1 {
+			findings = append(findings, Finding{
+				Rule:    RuleWildcardHostOrder,
+				Message: "Host * is not the last host block, so it overrides the blocks that follow it",
+				Host:    host,
+				Fix: &Fix{
+					Description:       "move Host * to the end of the config",
+					ChangesResolution: true,
+					edits:             []edit{{kind: editMoveHostToEnd, host: host}},
+				},
+			})
+		}
+	}
+
+	return findings
+
+}
+
+func lintParams(host *Host, params []*Param) []Finding {
+
+	var findings []Finding
+	seen := map[string]*Param{}
+
+	for _, param := range params {
+
+		if param.Keyword == "" {
+			continue
+		}
+
+		if info, ok := LookupKeyword(param.Keyword); ok && info.ReplacedBy != "" {
+			findings = append(findings, Finding{
+				Rule:    RuleDeprecatedKeyword,
+				Message: fmt.Sprintf("%s is deprecated, use %s", param.Keyword, info.ReplacedBy),
+				Host:    host,
+				Param:   param,
+				Fix: &Fix{
+					Description: fmt.Sprintf("rename %s to %s", param.Keyword, info.ReplacedBy),
+					edits:       []edit{{kind: editRename, host: host, param: param, keyword: info.ReplacedBy}},
+				},
+			})
+		} else if ok && info.Removed {
+			findings = append(findings, Finding{
+				Rule:    RuleRemovedKeyword,
+				Message: fmt.Sprintf("%s is no longer supported by OpenSSH", param.Keyword),
+				Host:    host,
+				Param:   param,
+			})
+		}
+
+		if finding, ok := lintAlgorithms(host, param); ok {
+			findings = append(findings, finding)
+		}
+
+		key := canonicalKeyword(param.Keyword)
+		if first, ok := seen[key]; ok && !isRepeatable(param.Keyword) {
+			findings = append(findings, Finding{
+				Rule:    RuleDuplicateKeyword,
+				Message: fmt.Sprintf("%s is already set to %q in this block, so this value never takes effect", param.Keyword, strings.Join(first.Args, " ")),
+				Host:    host,
+				Param:   param,
+				Fix: &Fix{
+					Description: fmt.Sprintf("remove duplicate %s", param.Keyword),
+					edits:       []edit{{kind: editRemoveParam, host: host, param: param}},
+				},
+			})
+			continue
+		}
+		seen[key] = param
+
+	}
+
+	return findings
+
+}
+
+func lintAlgorithms(host *Host, param *Param) (Finding, bool) {
+
+	var weak []string
+	for keyword, algorithms := range weakAlgorithms {
+		if canonicalKeyword(param.Keyword) == canonicalKeyword(keyword) {
+			weak = algorithms
+		}
+	}
+
+	value := param.Value()
+	if weak == nil || value == "" || value[0] == '-' {
+		// a '-' list removes algorithms from the defaults, which is always safe
+		return Finding{}, false
+	}
+
+	prefix := ""
+	if value[0] == '+' || value[0] == '^' {
+		prefix, value = value[:1], value[1:]
+	}
+
+	var kept, dropped []string
+	for _, algorithm := range strings.Split(value, ",") {
+		if slices.Contains(weak, strings.ToLower(algorithm)) {
+			dropped = append(dropped, algorithm)
+		} else {
+			kept = append(kept, algorithm)
+		}
+	}
+
+	if len(dropped) == 0 {
+		return Finding{}, false
+	}
+
+	finding := Finding{
+		Rule:    RuleWeakAlgorithm,
+		Message: fmt.Sprintf("%s enables weak algorithms: %s", param.Keyword, strings.Join(dropped, ",")),
+		Host:    host,
+		Param:   param,
+	}
+
+	switch {
+	case len(kept) > 0:
+		finding.Fix = &Fix{
+			Description:       fmt.Sprintf("remove %s from %s", strings.Join(dropped, ","), param.Keyword),
+			ChangesResolution: true,
+			edits:             []edit{{kind: editSetArgs, host: host, param: param, args: []string{prefix + strings.Join(kept, ",")}}},
+		}
+	case prefix != "":
+		// appending or prefixing only weak algorithms: drop the directive
+		// and fall back to the defaults
+		finding.Fix = &Fix{
+			Description:       fmt.Sprintf("remove %s", param.Keyword),
+			ChangesResolution: true,
+			edits:             []edit{{kind: editRemoveParam, host: host, param: param}},
+		}
+	}
+
+	return finding, true
+
+}
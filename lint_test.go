@@ -0,0 +1,73 @@
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var lintConfigTest = `
+ChallengeResponseAuthentication no
+Ciphers aes128-ctr,3des-cbc
+
+Host *
+  User root
+
+Host dev
+  HostName 127.0.0.1
+  User ubuntu
+  user admin
+  IdentityFile ~/.ssh/one
+  IdentityFile ~/.ssh/two
+  Protocol 2
+`
+
+func TestLint(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(lintConfigTest))
+
+	assert.NoError(t, err)
+
+	var rules []string
+	for _, finding := range config.Lint() {
+		rules = append(rules, finding.Rule)
+	}
+
+	assert.Equal(t, []string{
+		RuleDeprecatedKeyword,
+		RuleWeakAlgorithm,
+		RuleDuplicateKeyword,
+		RuleRemovedKeyword,
+		RuleWildcardHostOrder,
+	}, rules)
+}
+
+func TestLint_WeakAlgorithms(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host legacy
+  KexAlgorithms +diffie-hellman-group1-sha1
+  MACs -hmac-md5
+`))
+
+	assert.NoError(t, err)
+
+	findings := config.Lint()
+
+	assert.Len(t, findings, 1)
+	assert.Equal(t, RuleWeakAlgorithm, findings[0].Rule)
+	assert.Equal(t, "remove KexAlgorithms", findings[0].Fix.Description)
+}
+
+func TestFindingString(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(lintConfigTest))
+
+	assert.NoError(t, err)
+
+	findings := config.Lint()
+
+	assert.Equal(t, "global: ChallengeResponseAuthentication: ChallengeResponseAuthentication is deprecated, use KbdInteractiveAuthentication (deprecated-keyword)", findings[0].String())
+	assert.Equal(t, `Host dev: user: user is already set to "ubuntu" in this block, so this value never takes effect (duplicate-keyword)`, findings[2].String())
+}
@@ -0,0 +1,7 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package sshconfig
+
+func lockFile(path string) (func(), error) {
+	return nil, ErrLockNotSupported
+}
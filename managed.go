@@ -0,0 +1,272 @@
+package sshconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned when the markers of a managed block are damaged
+var (
+	ErrUnbalancedMarkers = errors.New("sshconfig: unbalanced managed block markers")
+	ErrMarkerModified    = errors.New("sshconfig: managed block marker was edited by hand")
+)
+
+// BlockPosition selects where a managed block is kept in a file
+type BlockPosition int
+
+// Positions for managed blocks
+const (
+	// PositionBottom keeps the block at the end of the file
+	PositionBottom BlockPosition = iota
+	// PositionTop keeps the block at the start of the file
+	PositionTop
+	// PositionBeforeWildcardHost keeps the block just before the first
+	// Host * block, so its hosts are not overridden by it, or at the
+	// end of the file when there is none
+	PositionBeforeWildcardHost
+)
+
+// ManagedBlock is a section of a config file owned by a tool, delimited
+// by named BEGIN and END comment markers
+// It works on the raw file contents, so everything outside the markers
+// is kept byte for byte
+type ManagedBlock struct {
+	Name     string
+	Position BlockPosition
+}
+
+// NewManagedBlock creates a managed block with the given marker name
+func NewManagedBlock(name string, position BlockPosition) *ManagedBlock {
+	return &ManagedBlock{
+		Name:     name,
+		Position: position,
+	}
+}
+
+func (block *ManagedBlock) beginMarker() string {
+	return "# BEGIN " + block.Name
+}
+
+func (block *ManagedBlock) endMarker() string {
+	return "# END " + block.Name
+}
+
+// Find returns the contents of the managed block in src
+// The boolean is false when src has no such block
+func (block *ManagedBlock) Find(src []byte) (*Config, bool, error) {
+
+	lines := splitFileLines(src)
+
+	begin, end, err := block.locate(lines)
+	if err != nil || begin < 0 {
+		return nil, false, err
+	}
+
+	content, err := Parse(strings.NewReader(strings.Join(lines[begin+1:end], "")))
+	if err != nil {
+		return nil, false, err
+	}
+
+	// unwrap the Match all lines written around globals by Set
+	if len(content.Hosts) > 0 && isMatchAll(content.Hosts[0]) && len(content.Globals) == 0 {
+		content.Globals = content.Hosts[0].Params
+		content.Hosts = content.Hosts[1:]
+	}
+	if n := len(content.Hosts); n > 0 && isMatchAll(content.Hosts[n-1]) && len(content.Hosts[n-1].Params) == 0 {
+		content.Hosts = content.Hosts[:n-1]
+	}
+
+	return content, true, nil
+
+}
+
+// Set creates or replaces the managed block in src with content, and
+// moves it to the block's position
+func (block *ManagedBlock) Set(src []byte, content *Config) ([]byte, error) {
+
+	lines, err := block.remove(splitFileLines(src))
+	if err != nil {
+		return nil, err
+	}
+
+	at := len(lines)
+	switch block.Position {
+	case PositionTop:
+		at = 0
+	case PositionBeforeWildcardHost:
+		at = wildcardHostLine(lines)
+	}
+
+	if at > 0 && !strings.HasSuffix(lines[at-1], "\n") {
+		lines[at-1] += "\n"
+	}
+
+	rendered := block.render(content, inHostContext(lines[:at]), followedByGlobals(lines[at:]))
+	lines = append(lines[:at:at], append([]string{rendered}, lines[at:]...)...)
+
+	return []byte(strings.Join(lines, "")), nil
+
+}
+
+// Remove deletes the managed block from src
+// src is returned unchanged when it has no such block
+func (block *ManagedBlock) Remove(src []byte) ([]byte, error) {
+
+	lines, err := block.remove(splitFileLines(src))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join(lines, "")), nil
+
+}
+
+func (block *ManagedBlock) remove(lines []string) ([]string, error) {
+
+	begin, end, err := block.locate(lines)
+	if err != nil || begin < 0 {
+		return lines, err
+	}
+
+	return append(lines[:begin:begin], lines[end+1:]...), nil
+
+}
+
+// locate returns the line indexes of the markers, or -1 when there are none
+// Lines that look like one of the markers but differ from it, such as a
+// changed case or spacing, are reported as ErrMarkerModified
+func (block *ManagedBlock) locate(lines []string) (int, int, error) {
+
+	begin, end := -1, -1
+	beginMarker, endMarker := block.beginMarker(), block.endMarker()
+
+	for i, line := range lines {
+
+		line = strings.TrimRight(line, "\r\n")
+		loose := strings.ToLower(strings.Join(strings.Fields(strings.TrimLeft(line, "# \t")), " "))
+
+		switch {
+		case line == beginMarker:
+			if begin >= 0 {
+				return -1, -1, fmt.Errorf("%w: %q appears twice", ErrUnbalancedMarkers, beginMarker)
+			}
+			begin = i
+		case line == endMarker:
+			if begin < 0 || end >= 0 {
+				return -1, -1, fmt.Errorf("%w: %q without %q", ErrUnbalancedMarkers, endMarker, beginMarker)
+			}
+			end = i
+		case strings.HasPrefix(strings.TrimSpace(line), "#") &&
+			(loose == strings.ToLower("BEGIN "+block.Name) || loose == strings.ToLower("END "+block.Name)):
+			return -1, -1, fmt.Errorf("%w: line %d: %q", ErrMarkerModified, i+1, line)
+		}
+
+	}
+
+	if begin >= 0 && end < 0 {
+		return -1, -1, fmt.Errorf("%w: %q without %q", ErrUnbalancedMarkers, beginMarker, endMarker)
+	}
+
+	return begin, end, nil
+
+}
+
+// render writes the block with its markers
+// Globals following a Host line would belong to that host, so they are
+// put under Match all when the block sits after a Host or Match line.
+// Likewise the block ends with Match all when global lines follow it
+func (block *ManagedBlock) render(content *Config, inHost, beforeGlobals bool) string {
+
+	opts := DefaultFormatOptions()
+	opts.Headers = false
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, block.beginMarker())
+
+	if globals := formatParams(content.Globals, "", opts); globals != "" {
+		if inHost {
+			fmt.Fprintln(buf, MatchKeyword+" all")
+			globals = formatParams(content.Globals, strings.Repeat(" ", opts.Indent), opts)
+		}
+		buf.WriteString(globals)
+		if len(content.Hosts) > 0 {
+			fmt.Fprintln(buf)
+		}
+	}
+
+	(&Config{Hosts: content.Hosts}).WriteFormatted(buf, opts)
+
+	if len(content.Hosts) > 0 && beforeGlobals {
+		fmt.Fprintln(buf, MatchKeyword+" all")
+	}
+
+	fmt.Fprintln(buf, block.endMarker())
+
+	return buf.String()
+
+}
+
+func isMatchAll(host *Host) bool {
+	return len(host.Criteria) == 1 && strings.EqualFold(host.Criteria[0], "all")
+}
+
+// splitFileLines splits src into lines, keeping their line endings
+func splitFileLines(src []byte) []string {
+	if len(src) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func lineKeyword(line string) (string, []string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return "", nil
+	}
+	return fields[0], fields[1:]
+}
+
+// wildcardHostLine returns the index of the first Host * line, including
+// the comment lines directly above it, or len(lines) when there is none
+func wildcardHostLine(lines []string) int {
+	for i, line := range lines {
+		keyword, args := lineKeyword(line)
+		if !strings.EqualFold(keyword, HostKeyword) || len(args) != 1 || args[0] != "*" {
+			continue
+		}
+		for i > 0 && strings.HasPrefix(strings.TrimSpace(lines[i-1]), "#") {
+			i--
+		}
+		return i
+	}
+	return len(lines)
+}
+
+// inHostContext reports whether lines leave ssh inside a Host or Match block
+func inHostContext(lines []string) bool {
+	for _, line := range lines {
+		keyword, _ := lineKeyword(line)
+		if strings.EqualFold(keyword, HostKeyword) || strings.EqualFold(keyword, MatchKeyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// followedByGlobals reports whether lines start with global parameters
+func followedByGlobals(lines []string) bool {
+	for _, line := range lines {
+		keyword, _ := lineKeyword(line)
+		if keyword == "" {
+			continue
+		}
+		return !strings.EqualFold(keyword, HostKeyword) && !strings.EqualFold(keyword, MatchKeyword)
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var managedConfigTest = `# my settings
+ServerAliveInterval 60
+
+Host personal
+  User me
+
+# catch-all
+Host *
+  ForwardAgent no
+`
+
+func managedContentTest(t *testing.T) *Config {
+
+	content, err := Parse(strings.NewReader(`
+IdentitiesOnly yes
+
+Host bastion
+  HostName bastion.example.com
+`))
+	assert.NoError(t, err)
+
+	return content
+}
+
+func TestManagedBlockSet(t *testing.T) {
+
+	block := NewManagedBlock("provisioner", PositionBeforeWildcardHost)
+
+	out, err := block.Set([]byte(managedConfigTest), managedContentTest(t))
+	assert.NoError(t, err)
+
+	expected := `# my settings
+ServerAliveInterval 60
+
+Host personal
+  User me
+
+# BEGIN provisioner
+Match all
+  IdentitiesOnly yes
+
+Host bastion
+  HostName bastion.example.com
+# END provisioner
+# catch-all
+Host *
+  ForwardAgent no
+`
+
+	assert.Equal(t, expected, string(out))
+
+	content, found, err := block.Find(out)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "yes", content.GetParam(IdentitiesOnlyKeyword).Value())
+	assert.NotNil(t, content.GetHost("bastion"))
+
+	// replacing the block keeps everything else untouched
+	content.GetHost("bastion").GetParam(HostNameKeyword).Args = []string{"10.0.0.1"}
+	replaced, err := block.Set(out, content)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Replace(expected, "bastion.example.com", "10.0.0.1", 1), string(replaced))
+
+	removed, err := block.Remove(replaced)
+	assert.NoError(t, err)
+	assert.Equal(t, managedConfigTest, string(removed))
+}
+
+func TestManagedBlockSet_Top(t *testing.T) {
+
+	block := NewManagedBlock("provisioner", PositionTop)
+
+	out, err := block.Set([]byte(managedConfigTest), managedContentTest(t))
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(string(out), `# BEGIN provisioner
+IdentitiesOnly yes
+
+Host bastion
+  HostName bastion.example.com
+Match all
+# END provisioner
+# my settings
+`))
+
+	config, err := Parse(strings.NewReader(string(out)))
+	assert.NoError(t, err)
+	assert.Equal(t, "60", config.Resolve("personal").GetParam(ServerAliveIntervalKeyword).Value())
+}
+
+func TestManagedBlock_Markers(t *testing.T) {
+
+	block := NewManagedBlock("provisioner", PositionBottom)
+
+	_, found, err := block.Find([]byte(managedConfigTest))
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	_, _, err = block.Find([]byte("# BEGIN provisioner\nHost a\n"))
+	assert.ErrorIs(t, err, ErrUnbalancedMarkers)
+
+	_, err = block.Set([]byte("Host a\n# END provisioner\n"), &Config{})
+	assert.ErrorIs(t, err, ErrUnbalancedMarkers)
+
+	_, err = block.Remove([]byte("# BEGIN provisioner\nHost a\n#  end Provisioner\n"))
+	assert.ErrorIs(t, err, ErrMarkerModified)
+}
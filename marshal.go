@@ -0,0 +1,491 @@
+package sshconfig
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by Unmarshal and Marshal
+var (
+	ErrUnsupportedType = errors.New("sshconfig: unsupported type")
+	ErrMissingPatterns = errors.New("sshconfig: no host patterns")
+)
+
+// FieldError reports a struct field that could not be decoded or encoded
+type FieldError struct {
+	Field   string
+	Keyword string
+	Err     error
+}
+
+func (err *FieldError) Error() string {
+	return fmt.Sprintf("sshconfig: field %s (%s): %v", err.Field, err.Keyword, err.Err)
+}
+
+func (err *FieldError) Unwrap() error {
+	return err.Err
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// structField is an exported struct field and the keyword it maps to
+type structField struct {
+	index   int
+	name    string
+	keyword string
+}
+
+// structFields lists the fields of a struct type the way encoding/json
+// does: the ssh tag names the keyword, the field name is used when there
+// is no tag, and fields tagged "-" are skipped
+func structFields(t reflect.Type) []structField {
+
+	var fields []structField
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		keyword := field.Name
+		if tag := field.Tag.Get("ssh"); tag == "-" {
+			continue
+		} else if tag != "" {
+			keyword = tag
+		}
+		fields = append(fields, structField{index: i, name: field.Name, keyword: keyword})
+	}
+
+	return fields
+
+}
+
+// Unmarshal stores the parameters of host in the struct pointed to by v,
+// typically with a host returned by Resolve
+//
+//	type Target struct {
+//		Patterns []string      `ssh:"Host"`
+//		User     string        `ssh:"User"`
+//		Port     int           `ssh:"Port"`
+//		Keys     []string      `ssh:"IdentityFile"`
+//		Persist  time.Duration `ssh:"ControlPersist"`
+//	}
+//
+// Values are converted using the type of their keyword in the registry:
+// bool fields take yes or no, time.Duration fields take the ssh time
+// format and integer fields of duration keywords are set in seconds.
+// Slice fields receive every occurrence of a repeatable keyword, the
+// items of a comma-separated list or each argument of other keywords;
+// other fields receive the first occurrence only. Fields implementing
+// encoding.TextUnmarshaler decode the value themselves. Fields whose
+// keyword is missing are left untouched. A field tagged Host receives
+// the patterns of the block
+// Every field that fails to decode is reported as a *FieldError
+func Unmarshal(host *Host, v interface{}) error {
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: Unmarshal needs a pointer to a struct, not %T", ErrUnsupportedType, v)
+	}
+	rv = rv.Elem()
+
+	var errs []error
+
+	for _, field := range structFields(rv.Type()) {
+
+		var values [][]string
+		if strings.EqualFold(field.keyword, HostKeyword) {
+			if len(host.Hostnames) > 0 {
+				values = append(values, host.Hostnames)
+			}
+		} else {
+			key := canonicalKeyword(field.keyword)
+			for _, param := range host.Params {
+				if param.Keyword != "" && canonicalKeyword(param.Keyword) == key {
+					values = append(values, param.Args)
+				}
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		info, _ := LookupKeyword(field.keyword)
+		if err := decodeField(rv.Field(field.index), info, values); err != nil {
+			errs = append(errs, &FieldError{Field: field.name, Keyword: field.keyword, Err: err})
+		}
+
+	}
+
+	return errors.Join(errs...)
+
+}
+
+// decodeField stores the arguments of every occurrence of a keyword in a field
+func decodeField(value reflect.Value, info KeywordInfo, values [][]string) error {
+
+	if value.Kind() != reflect.Slice || reflect.PointerTo(value.Type()).Implements(textUnmarshalerType) {
+		return decodeValue(value, info, strings.Join(values[0], " "))
+	}
+
+	var items []string
+	switch {
+	case info.Repeatable:
+		for _, args := range values {
+			items = append(items, strings.Join(args, " "))
+		}
+	case info.Type == TypeList:
+		for _, arg := range values[0] {
+			items = append(items, strings.Split(arg, ",")...)
+		}
+	default:
+		items = values[0]
+	}
+
+	slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+	for i, item := range items {
+		if err := decodeValue(slice.Index(i), info, item); err != nil {
+			return err
+		}
+	}
+	value.Set(slice)
+
+	return nil
+
+}
+
+func decodeValue(value reflect.Value, info KeywordInfo, s string) error {
+
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return decodeValue(value.Elem(), info, s)
+	}
+
+	if value.CanAddr() {
+		if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(s))
+		}
+	}
+
+	if value.Type() == durationType {
+		d, err := parseDuration(info, s)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := parseFlag(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := parseNumber(info, s)
+		if err != nil {
+			return err
+		}
+		if value.OverflowInt(n) {
+			return fmt.Errorf("%s overflows %s", s, value.Type())
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := parseNumber(info, s)
+		if err != nil {
+			return err
+		}
+		if n < 0 || value.OverflowUint(uint64(n)) {
+			return fmt.Errorf("%s overflows %s", s, value.Type())
+		}
+		value.SetUint(uint64(n))
+	default:
+		return fmt.Errorf("%w %s", ErrUnsupportedType, value.Type())
+	}
+
+	return nil
+
+}
+
+// parseNumber reads an integer, in seconds for duration keywords,
+// where no reads as -1
+func parseNumber(info KeywordInfo, s string) (int64, error) {
+	if info.Type == TypeDuration {
+		d, err := parseDuration(info, s)
+		if d == DurationDisabled {
+			return -1, err
+		}
+		return int64(d / time.Second), err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func parseFlag(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "true":
+		return true, nil
+	case "no", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not yes or no", s)
+}
+
+// DurationDisabled is the duration read for a no value, which
+// ControlPersist takes to turn persistence off
+const DurationDisabled time.Duration = -1
+
+// parseDuration reads the value of a duration keyword
+// ControlPersist also takes yes, meaning no timeout, which reads as 0,
+// and no, which reads as DurationDisabled
+func parseDuration(info KeywordInfo, s string) (time.Duration, error) {
+	if strings.EqualFold(info.Name, ControlPersistKeyword) {
+		switch strings.ToLower(s) {
+		case "yes":
+			return 0, nil
+		case "no":
+			return DurationDisabled, nil
+		}
+	}
+	return parseTime(s)
+}
+
+// parseTime reads the ssh time format: a sequence of numbers, each
+// followed by an optional unit of s, m, h, d or w, where numbers without
+// a unit count seconds
+func parseTime(s string) (time.Duration, error) {
+
+	if s == "" {
+		return 0, fmt.Errorf("%q is not a time", s)
+	}
+
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("%q is not a time", s)
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a time", s)
+		}
+		unit := time.Second
+		if i < len(rest) {
+			var ok bool
+			if unit, ok = units[rest[i]|0x20]; !ok {
+				return 0, fmt.Errorf("%q is not a time", s)
+			}
+			i++
+		}
+		if n > int64(math.MaxInt64-total)/int64(unit) {
+			return 0, fmt.Errorf("%s overflows %s", s, durationType)
+		}
+		total += time.Duration(n) * unit
+		rest = rest[i:]
+	}
+
+	return total, nil
+
+}
+
+// formatDuration writes the value of a duration keyword, and
+// DurationDisabled as no for ControlPersist
+func formatDuration(info KeywordInfo, d time.Duration) (string, error) {
+	if d == DurationDisabled && strings.EqualFold(info.Name, ControlPersistKeyword) {
+		return "no", nil
+	}
+	return formatTime(d)
+}
+
+// formatTime writes d in the ssh time format, such as 1h30m
+func formatTime(d time.Duration) (string, error) {
+
+	if d < 0 || d%time.Second != 0 {
+		return "", fmt.Errorf("%s cannot be written in whole seconds", d)
+	}
+	if d == 0 {
+		return "0", nil
+	}
+
+	buf := &strings.Builder{}
+	for _, unit := range []struct {
+		symbol string
+		size   time.Duration
+	}{
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	} {
+		if n := d / unit.size; n > 0 {
+			fmt.Fprintf(buf, "%d%s", n, unit.symbol)
+			d -= n * unit.size
+		}
+	}
+
+	return buf.String(), nil
+
+}
+
+// Marshal creates a Host block from a struct described like the ones
+// given to Unmarshal
+// The field tagged Host holds the patterns of the block and must be set.
+// Parameters are written in field order, using the field's tag as the
+// keyword. Slices of repeatable keywords give one parameter per item and
+// slices of list keywords are joined with commas. Zero values are left
+// out, so use a pointer to write a no flag or a zero number
+func Marshal(v interface{}) (*Host, error) {
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: Marshal needs a struct, not %T", ErrUnsupportedType, v)
+	}
+
+	// copy the struct so methods with pointer receivers can be called
+	addressable := reflect.New(rv.Type()).Elem()
+	addressable.Set(rv)
+	rv = addressable
+
+	host := NewHost(nil, nil)
+	var errs []error
+
+	for _, field := range structFields(rv.Type()) {
+
+		value := rv.Field(field.index)
+		if value.IsZero() {
+			continue
+		}
+
+		info, _ := LookupKeyword(field.keyword)
+		occurrences, err := encodeField(value, info)
+		if err != nil {
+			errs = append(errs, &FieldError{Field: field.name, Keyword: field.keyword, Err: err})
+			continue
+		}
+
+		if strings.EqualFold(field.keyword, HostKeyword) {
+			for _, args := range occurrences {
+				host.Hostnames = append(host.Hostnames, args...)
+			}
+			continue
+		}
+		for _, args := range occurrences {
+			host.AddParam(NewParam(field.keyword, args, nil))
+		}
+
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if len(host.Hostnames) == 0 {
+		return nil, fmt.Errorf("%w: set a field tagged ssh:%q", ErrMissingPatterns, HostKeyword)
+	}
+
+	return host, nil
+
+}
+
+// encodeField returns the arguments of every parameter a field is written as
+func encodeField(value reflect.Value, info KeywordInfo) ([][]string, error) {
+
+	if value.Kind() != reflect.Slice || reflect.PointerTo(value.Type()).Implements(textMarshalerType) {
+		s, err := encodeValue(value, info)
+		if err != nil {
+			return nil, err
+		}
+		return [][]string{{s}}, nil
+	}
+
+	var items []string
+	for i := 0; i < value.Len(); i++ {
+		s, err := encodeValue(value.Index(i), info)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+
+	switch {
+	case info.Repeatable:
+		var occurrences [][]string
+		for _, item := range items {
+			occurrences = append(occurrences, []string{item})
+		}
+		return occurrences, nil
+	case info.Type == TypeList:
+		return [][]string{{strings.Join(items, ",")}}, nil
+	}
+
+	return [][]string{items}, nil
+
+}
+
+func encodeValue(value reflect.Value, info KeywordInfo) (string, error) {
+
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return "", fmt.Errorf("nil %s", value.Type())
+		}
+		return encodeValue(value.Elem(), info)
+	}
+
+	if value.CanAddr() {
+		if marshaler, ok := value.Addr().Interface().(encoding.TextMarshaler); ok {
+			text, err := marshaler.MarshalText()
+			return string(text), err
+		}
+	}
+
+	if value.Type() == durationType {
+		return formatDuration(info, time.Duration(value.Int()))
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		if value.Bool() {
+			return "yes", nil
+		}
+		return "no", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	}
+
+	return "", fmt.Errorf("%w %s", ErrUnsupportedType, value.Type())
+
+}
@@ -0,0 +1,187 @@
+package sshconfig
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var marshalConfigTest = `Host dev
+  HostName 10.0.0.1
+  User ubuntu
+  Port 2222
+  IdentityFile ~/.ssh/dev
+  ControlPersist 1h30m
+  ServerAliveInterval 2m
+  Ciphers aes256-gcm@openssh.com,chacha20-poly1305@openssh.com
+  ForwardAgent yes
+
+Host *
+  IdentityFile ~/.ssh/default
+  Port 22
+`
+
+type marshalTarget struct {
+	Patterns []string      `ssh:"Host"`
+	Address  net.IP        `ssh:"HostName"`
+	User     string        `ssh:"User"`
+	Port     int           `ssh:"Port"`
+	Keys     []string      `ssh:"IdentityFile"`
+	Persist  time.Duration `ssh:"ControlPersist"`
+	Alive    int           `ssh:"ServerAliveInterval"`
+	Ciphers  []string      `ssh:"Ciphers"`
+	Agent    *bool         `ssh:"ForwardAgent"`
+	Ignored  string        `ssh:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(marshalConfigTest))
+	assert.NoError(t, err)
+
+	var target marshalTarget
+	assert.NoError(t, Unmarshal(config.Resolve("dev"), &target))
+
+	assert.Equal(t, []string{"dev"}, target.Patterns)
+	assert.Equal(t, "10.0.0.1", target.Address.String())
+	assert.Equal(t, "ubuntu", target.User)
+	assert.Equal(t, 2222, target.Port)
+	assert.Equal(t, []string{"~/.ssh/dev", "~/.ssh/default"}, target.Keys)
+	assert.Equal(t, 90*time.Minute, target.Persist)
+	assert.Equal(t, 120, target.Alive)
+	assert.Equal(t, []string{"aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com"}, target.Ciphers)
+	assert.True(t, *target.Agent)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+
+	config, err := Parse(strings.NewReader("Host dev\n  Port ssh\n  ForwardAgent maybe\n  User ubuntu\n"))
+	assert.NoError(t, err)
+
+	var target struct {
+		Port  uint8  `ssh:"Port"`
+		Agent bool   `ssh:"ForwardAgent"`
+		User  string `ssh:"User"`
+	}
+	err = Unmarshal(config.Hosts[0], &target)
+	assert.Error(t, err)
+	assert.Equal(t, "ubuntu", target.User)
+
+	var fieldErr *FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "Port", fieldErr.Field)
+	assert.Contains(t, err.Error(), "field Agent (ForwardAgent)")
+
+	assert.ErrorIs(t, Unmarshal(config.Hosts[0], target), ErrUnsupportedType)
+}
+
+func TestMarshal(t *testing.T) {
+
+	agent := false
+	host, err := Marshal(marshalTarget{
+		Patterns: []string{"dev", "dev.example.com"},
+		Address:  net.ParseIP("10.0.0.1"),
+		User:     "ubuntu",
+		Keys:     []string{"~/.ssh/dev", "~/.ssh/backup"},
+		Persist:  90 * time.Minute,
+		Ciphers:  []string{"aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com"},
+		Agent:    &agent,
+		Ignored:  "ignored",
+	})
+	assert.NoError(t, err)
+
+	expected := `
+Host dev dev.example.com
+  HostName 10.0.0.1
+  User ubuntu
+  IdentityFile ~/.ssh/dev
+  IdentityFile ~/.ssh/backup
+  ControlPersist 1h30m
+  Ciphers aes256-gcm@openssh.com,chacha20-poly1305@openssh.com
+  ForwardAgent no
+`
+	assert.Equal(t, expected, host.String())
+
+	var target marshalTarget
+	assert.NoError(t, Unmarshal(host, &target))
+	assert.Equal(t, 90*time.Minute, target.Persist)
+
+	_, err = Marshal(marshalTarget{User: "ubuntu"})
+	assert.ErrorIs(t, err, ErrMissingPatterns)
+
+	_, err = Marshal(struct {
+		Patterns string        `ssh:"Host"`
+		Timeout  time.Duration `ssh:"ConnectTimeout"`
+	}{"dev", 1500 * time.Millisecond})
+	var fieldErr *FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "Timeout", fieldErr.Field)
+}
+
+func TestParseTime(t *testing.T) {
+
+	for s, expected := range map[string]time.Duration{
+		"90":     90 * time.Second,
+		"10m":    10 * time.Minute,
+		"1h30M":  90 * time.Minute,
+		"1w2d":   9 * 24 * time.Hour,
+		"5m30s1": 5*time.Minute + 31*time.Second,
+	} {
+		d, err := parseTime(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, d, s)
+	}
+
+	for _, s := range []string{"", "never", "1x", "m", "yes", "no", "99999999999w", "9223372036s1s"} {
+		_, err := parseTime(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+
+	persist, _ := LookupKeyword(ControlPersistKeyword)
+	interval, _ := LookupKeyword(ServerAliveIntervalKeyword)
+
+	d, err := parseDuration(persist, "yes")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), d)
+	d, err = parseDuration(persist, "No")
+	assert.NoError(t, err)
+	assert.Equal(t, DurationDisabled, d)
+
+	for _, s := range []string{"yes", "no"} {
+		_, err = parseDuration(interval, s)
+		assert.Error(t, err, s)
+		assert.ErrorIs(t, checkArgs(interval, []string{s}), ErrInvalidValue, s)
+	}
+
+	config, err := Parse(strings.NewReader("Host web\n  ControlPersist 99999999999w\n  ServerAliveInterval no\n"))
+	assert.NoError(t, err)
+	var target marshalTarget
+	err = Unmarshal(config.GetHost("web"), &target)
+	var fieldErr *FieldError
+	assert.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, ControlPersistKeyword, fieldErr.Keyword)
+}
+
+func TestControlPersistNo(t *testing.T) {
+
+	config, err := Parse(strings.NewReader("Host web\n  ControlPersist no\n"))
+	assert.NoError(t, err)
+
+	var target marshalTarget
+	assert.NoError(t, Unmarshal(config.GetHost("web"), &target))
+	assert.Equal(t, DurationDisabled, target.Persist)
+
+	host, err := Marshal(&target)
+	assert.NoError(t, err)
+	assert.Equal(t, "no", host.GetParam(ControlPersistKeyword).Value())
+
+	info, _ := LookupKeyword(ControlPersistKeyword)
+	assert.NoError(t, checkArgs(info, []string{"no"}))
+}
@@ -0,0 +1,322 @@
+package sshconfig
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MergeOptions controls how Merge combines configs
+type MergeOptions struct {
+	// ConflictMarkers writes git-style conflict markers as comments above
+	// every conflicting parameter or block, so the merged config can be
+	// reviewed by hand and is still valid ssh_config
+	ConflictMarkers bool
+}
+
+// Conflict is a node that ours and theirs both changed, in different ways
+type Conflict struct {
+	// Keyword and Patterns identify the block; both are empty for
+	// conflicts between global parameters
+	Keyword  string
+	Patterns []string
+	// Param is the keyword of the conflicting parameter, or empty
+	// when the conflict concerns the whole block
+	Param string
+	// Base, Ours and Theirs hold the arguments of the parameter on each
+	// side, nil where the parameter does not exist
+	Base   []string
+	Ours   []string
+	Theirs []string
+	Reason string
+}
+
+func (conflict Conflict) String() string {
+
+	location := "global"
+	if conflict.Keyword != "" {
+		location = conflict.Keyword + " " + strings.Join(conflict.Patterns, " ")
+	}
+	if conflict.Param != "" {
+		location += ": " + conflict.Param
+	}
+
+	return fmt.Sprintf("%s: %s", location, conflict.Reason)
+
+}
+
+// Merge combines the changes ours and theirs each made to base, the way
+// a three-way merge in git does, at the level of blocks and parameters
+// See MergeWithOptions
+func Merge(base, ours, theirs *Config) (*Config, []Conflict) {
+	return MergeWithOptions(base, ours, theirs, MergeOptions{})
+}
+
+// MergeWithOptions combines the changes ours and theirs each made to base
+// A change made by only one side is applied. When both sides changed a
+// node differently, ours wins, unless ours deleted a node theirs modified,
+// in which case the modified node is kept. Either way a Conflict is
+// reported. The inputs are not modified
+func MergeWithOptions(base, ours, theirs *Config, opts MergeOptions) (*Config, []Conflict) {
+
+	merged := &Config{}
+	var conflicts []Conflict
+
+	merged.Globals, conflicts = mergeParams(nil, base.Globals, ours.Globals, theirs.Globals, opts)
+
+	baseKeys, ourKeys, theirKeys := blockKeys(base.Hosts), blockKeys(ours.Hosts), blockKeys(theirs.Hosts)
+	baseByKey, ourByKey, theirByKey := hostsByKey(baseKeys, base.Hosts), hostsByKey(ourKeys, ours.Hosts), hostsByKey(theirKeys, theirs.Hosts)
+
+	for _, key := range mergeOrder(baseKeys, ourKeys, theirKeys) {
+
+		b, o, t := baseByKey[key], ourByKey[key], theirByKey[key]
+
+		switch {
+		case o != nil && t != nil:
+			host := o.Clone()
+			var baseParams []*Param
+			if b != nil {
+				baseParams = b.Params
+			}
+			var blockConflicts []Conflict
+			host.Params, blockConflicts = mergeParams(host, baseParams, o.Params, t.Params, opts)
+			conflicts = append(conflicts, blockConflicts...)
+			merged.Hosts = append(merged.Hosts, host)
+
+		case b == nil:
+			// added by one side only
+			if o == nil {
+				o = t
+			}
+			merged.Hosts = append(merged.Hosts, o.Clone())
+
+		case o == nil && t == nil:
+			// deleted by both sides
+
+		default:
+			kept, reason := o, "modified in ours, deleted in theirs"
+			if o == nil {
+				kept, reason = t, "deleted in ours, modified in theirs"
+			}
+			if len(diffParams(b.Params, kept.Params)) == 0 {
+				// the other side deleted a block this side left alone
+				continue
+			}
+			host := kept.Clone()
+			conflicts = append(conflicts, Conflict{
+				Keyword:  host.keyword(),
+				Patterns: host.patterns(),
+				Reason:   reason,
+			})
+			if opts.ConflictMarkers {
+				line := host.header()
+				ourSide, theirSide := line+" (modified)", line+" (deleted)"
+				if o == nil {
+					ourSide, theirSide = theirSide, ourSide
+				}
+				host.Comments = append(conflictMarkers(ourSide, theirSide), host.Comments...)
+			}
+			merged.Hosts = append(merged.Hosts, host)
+		}
+
+	}
+
+	return merged, conflicts
+
+}
+
+// mergeParams merges the parameters of one block; host is nil for globals
+func mergeParams(host *Host, base, ours, theirs []*Param, opts MergeOptions) ([]*Param, []Conflict) {
+
+	var (
+		merged    []*Param
+		conflicts []Conflict
+	)
+
+	baseKeys, ourKeys, theirKeys := paramKeys(base), paramKeys(ours), paramKeys(theirs)
+
+	// parameters holding only comments are kept from ours
+	for i, key := range ourKeys {
+		if key == "" {
+			ourKeys[i] = fmt.Sprintf("#comment%d", i)
+		}
+	}
+
+	baseByKey, ourByKey, theirByKey := paramsByKey(baseKeys, base), paramsByKey(ourKeys, ours), paramsByKey(theirKeys, theirs)
+
+	for _, key := range mergeOrder(baseKeys, ourKeys, theirKeys) {
+
+		if key == "" {
+			continue
+		}
+
+		b, o, t := baseByKey[key], ourByKey[key], theirByKey[key]
+
+		var chosen *Param
+		switch {
+		case paramsEqual(o, t), paramsEqual(b, t):
+			chosen = o
+		case paramsEqual(b, o):
+			chosen = t
+		default:
+			chosen = o
+			if chosen == nil {
+				chosen = t
+			}
+			conflict := Conflict{
+				Param:  chosen.Keyword,
+				Base:   paramArgs(b),
+				Ours:   paramArgs(o),
+				Theirs: paramArgs(t),
+				Reason: conflictReason(b, o, t),
+			}
+			if host != nil {
+				conflict.Keyword, conflict.Patterns = host.keyword(), host.patterns()
+			}
+			conflicts = append(conflicts, conflict)
+			if opts.ConflictMarkers {
+				chosen = chosen.Clone()
+				chosen.Comments = append(conflictMarkers(paramLine(o), paramLine(t)), chosen.Comments...)
+				merged = append(merged, chosen)
+				continue
+			}
+		}
+
+		if chosen != nil {
+			merged = append(merged, chosen.Clone())
+		}
+
+	}
+
+	return merged, conflicts
+
+}
+
+// mergeOrder lists the keys of the merged result
+// The result follows the order of ours, unless only theirs reordered
+// the nodes it shares with base; keys from the other side are slotted in
+// before the key that follows them there
+func mergeOrder(base, ours, theirs []string) []string {
+
+	skeleton, other := ours, theirs
+	if sameOrder(base, ours) && !sameOrder(base, theirs) {
+		skeleton, other = theirs, ours
+	}
+
+	order := append([]string(nil), skeleton...)
+	present := map[string]bool{}
+	for _, key := range order {
+		present[key] = true
+	}
+
+	for i := len(other) - 1; i >= 0; i-- {
+		key := other[i]
+		if present[key] {
+			continue
+		}
+		at := len(order)
+		if i+1 < len(other) {
+			at = indexOf(order, other[i+1])
+		}
+		order = append(order[:at], append([]string{key}, order[at:]...)...)
+		present[key] = true
+	}
+
+	return order
+
+}
+
+// sameOrder reports whether the keys that a and b share appear in the same order
+func sameOrder(a, b []string) bool {
+
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, key := range a {
+		inA[key] = true
+	}
+	for _, key := range b {
+		inB[key] = true
+	}
+
+	var sharedA, sharedB []string
+	for _, key := range a {
+		if inB[key] {
+			sharedA = append(sharedA, key)
+		}
+	}
+	for _, key := range b {
+		if inA[key] {
+			sharedB = append(sharedB, key)
+		}
+	}
+
+	return strings.Join(sharedA, "\n") == strings.Join(sharedB, "\n")
+
+}
+
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func hostsByKey(keys []string, hosts []*Host) map[string]*Host {
+	byKey := map[string]*Host{}
+	for i, key := range keys {
+		byKey[key] = hosts[i]
+	}
+	return byKey
+}
+
+func paramsByKey(keys []string, params []*Param) map[string]*Param {
+	byKey := map[string]*Param{}
+	for i, key := range keys {
+		byKey[key] = params[i]
+	}
+	return byKey
+}
+
+func paramsEqual(a, b *Param) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Keyword == b.Keyword && argsEqual(a.Args, b.Args)
+}
+
+func paramArgs(param *Param) []string {
+	if param == nil {
+		return nil
+	}
+	return param.Args
+}
+
+func paramLine(param *Param) string {
+	if param == nil {
+		return "(deleted)"
+	}
+	return param.Keyword + " " + strings.Join(param.Args, " ")
+}
+
+func conflictReason(base, ours, theirs *Param) string {
+	switch {
+	case base == nil:
+		return "added differently in ours and theirs"
+	case ours == nil:
+		return "deleted in ours, modified in theirs"
+	case theirs == nil:
+		return "modified in ours, deleted in theirs"
+	}
+	return "modified differently in ours and theirs"
+}
+
+// conflictMarkers returns git-style conflict markers as comment lines
+func conflictMarkers(ours, theirs string) []string {
+	return []string{
+		"# <<<<<<< ours",
+		"# " + ours,
+		"# =======",
+		"# " + theirs,
+		"# >>>>>>> theirs",
+	}
+}
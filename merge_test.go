@@ -0,0 +1,170 @@
+package sshconfig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	mergeBaseTest = `
+VisualHostKey yes
+
+Host dev
+  HostName 127.0.0.1
+  User ubuntu
+  Port 22
+
+Host old
+  User root
+
+Host stale
+  User root
+`
+
+	mergeOursTest = `
+VisualHostKey yes
+
+Host dev
+  HostName 127.0.0.1
+  User admin
+  Port 2222
+
+Host stale
+  User root
+  Port 2200
+
+Host mine
+  User me
+`
+
+	mergeTheirsTest = `
+VisualHostKey no
+
+Host dev
+  HostName 10.0.0.1
+  User deploy
+  Port 22
+
+Host theirs
+  User them
+`
+)
+
+func parseMergeTest(t *testing.T) (*Config, *Config, *Config) {
+
+	base, err := Parse(strings.NewReader(mergeBaseTest))
+	assert.NoError(t, err)
+
+	ours, err := Parse(strings.NewReader(mergeOursTest))
+	assert.NoError(t, err)
+
+	theirs, err := Parse(strings.NewReader(mergeTheirsTest))
+	assert.NoError(t, err)
+
+	return base, ours, theirs
+}
+
+func TestMerge(t *testing.T) {
+
+	base, ours, theirs := parseMergeTest(t)
+
+	merged, conflicts := Merge(base, ours, theirs)
+
+	expected := `
+# global configuration
+VisualHostKey no
+
+# host-based configuration
+
+Host dev
+  HostName 10.0.0.1
+  User admin
+  Port 2222
+
+Host stale
+  User root
+  Port 2200
+
+Host mine
+  User me
+
+Host theirs
+  User them
+`
+
+	var b bytes.Buffer
+	merged.WriteTo(&b)
+
+	assert.Equal(t, expected, b.String())
+
+	assert.Len(t, conflicts, 2)
+	assert.Equal(t, "Host dev: User: modified differently in ours and theirs", conflicts[0].String())
+	assert.Equal(t, []string{"ubuntu"}, conflicts[0].Base)
+	assert.Equal(t, []string{"admin"}, conflicts[0].Ours)
+	assert.Equal(t, []string{"deploy"}, conflicts[0].Theirs)
+	assert.Equal(t, "Host stale: modified in ours, deleted in theirs", conflicts[1].String())
+
+	assert.Equal(t, "ubuntu", base.GetHost("dev").GetParam(UserKeyword).Value())
+}
+
+func TestMerge_ConflictMarkers(t *testing.T) {
+
+	base, ours, theirs := parseMergeTest(t)
+
+	merged, _ := MergeWithOptions(base, ours, theirs, MergeOptions{ConflictMarkers: true})
+
+	expected := `
+# <<<<<<< ours
+# Host stale (modified)
+# =======
+# Host stale (deleted)
+# >>>>>>> theirs
+Host stale
+  User root
+  Port 2200
+`
+
+	assert.Equal(t, expected, merged.GetHost("stale").String())
+
+	var b bytes.Buffer
+	merged.WriteFormatted(&b, DefaultFormatOptions())
+
+	assert.Contains(t, b.String(), `Host dev
+  HostName 10.0.0.1
+  # <<<<<<< ours
+  # User admin
+  # =======
+  # User deploy
+  # >>>>>>> theirs
+  User admin
+`)
+
+	reparsed, err := Parse(&b)
+	assert.NoError(t, err)
+	assert.True(t, Diff(merged, reparsed).Empty())
+}
+
+func TestMerge_Reordered(t *testing.T) {
+
+	base, err := Parse(strings.NewReader("Host a\nHost b\nHost c\n"))
+	assert.NoError(t, err)
+
+	ours, err := Parse(strings.NewReader("Host a\nHost b\nHost c\nHost d\n"))
+	assert.NoError(t, err)
+
+	theirs, err := Parse(strings.NewReader("Host c\nHost a\nHost b\n"))
+	assert.NoError(t, err)
+
+	merged, conflicts := Merge(base, ours, theirs)
+
+	var patterns []string
+	for _, host := range merged.Hosts {
+		patterns = append(patterns, host.Hostnames...)
+	}
+
+	assert.Empty(t, conflicts)
+	assert.Equal(t, []string{"c", "a", "b", "d"}, patterns)
+}
@@ -0,0 +1,246 @@
+package sshconfig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by the editing methods, alongside ErrNoSuchBlock,
+// ErrNoSuchParam and ErrBlockExists
+var (
+	ErrOutOfRange     = errors.New("sshconfig: index out of range")
+	ErrNoSuchPattern  = errors.New("sshconfig: no such pattern")
+	ErrInvalidPattern = errors.New("sshconfig: invalid pattern")
+)
+
+// RemoveHost removes a Host or Match block, along with its comments
+func (config *Config) RemoveHost(host *Host) error {
+	return config.edit("RemoveHost "+hostAddress(host), func() error {
+		i := indexOfHost(config.Hosts, host)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrNoSuchBlock, hostAddress(host))
+		}
+		config.Hosts = append(config.Hosts[:i:i], config.Hosts[i+1:]...)
+		return nil
+	})
+}
+
+// InsertHostBefore inserts host just before the block mark
+func (config *Config) InsertHostBefore(mark, host *Host) error {
+	return config.edit("InsertHostBefore "+hostAddress(mark)+": "+hostAddress(host), func() error {
+		return config.insertHost(mark, host, 0)
+	})
+}
+
+// InsertHostAfter inserts host just after the block mark
+func (config *Config) InsertHostAfter(mark, host *Host) error {
+	return config.edit("InsertHostAfter "+hostAddress(mark)+": "+hostAddress(host), func() error {
+		return config.insertHost(mark, host, 1)
+	})
+}
+
+func (config *Config) insertHost(mark, host *Host, offset int) error {
+
+	if host == nil {
+		return fmt.Errorf("%w: nil", ErrNoSuchBlock)
+	}
+	if indexOfHost(config.Hosts, host) >= 0 {
+		return fmt.Errorf("%w: %s", ErrBlockExists, hostAddress(host))
+	}
+
+	i := indexOfHost(config.Hosts, mark)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrNoSuchBlock, hostAddress(mark))
+	}
+
+	at := i + offset
+	config.Hosts = append(config.Hosts[:at:at], append([]*Host{host}, config.Hosts[at:]...)...)
+
+	return nil
+
+}
+
+// MoveHost moves a block so that it ends up at the given index of Hosts
+// Its comments move with it
+func (config *Config) MoveHost(host *Host, index int) error {
+	return config.edit(fmt.Sprintf("MoveHost %s to %d", hostAddress(host), index), func() error {
+
+		i := indexOfHost(config.Hosts, host)
+		if i < 0 {
+			return fmt.Errorf("%w: %s", ErrNoSuchBlock, hostAddress(host))
+		}
+		if index < 0 || index >= len(config.Hosts) {
+			return fmt.Errorf("%w: %d", ErrOutOfRange, index)
+		}
+
+		hosts := append(config.Hosts[:i:i], config.Hosts[i+1:]...)
+		config.Hosts = append(hosts[:index:index], append([]*Host{host}, hosts[index:]...)...)
+
+		return nil
+
+	})
+}
+
+// RenamePattern replaces one pattern of a Host block, or one argument
+// of the criteria of a Match block
+func (host *Host) RenamePattern(old, new string) error {
+
+	if new == "" {
+		return fmt.Errorf("%w: empty pattern", ErrInvalidPattern)
+	}
+
+	patterns := host.patterns()
+	for i, pattern := range patterns {
+		if pattern == old {
+			patterns[i] = new
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q in %s", ErrNoSuchPattern, old, host.header())
+
+}
+
+// RemoveParam removes a parameter of the block
+// Its comments are handed to the parameter that follows it
+func (host *Host) RemoveParam(param *Param) error {
+	params, err := removeParamNode(host.Params, param)
+	if err != nil {
+		return err
+	}
+	host.Params = params
+	return nil
+}
+
+// RemoveParams removes every occurrence of a keyword from the block
+// Keywords are matched case-insensitively, and deprecated aliases match
+// the keyword that replaced them
+func (host *Host) RemoveParams(keyword string) error {
+	params, err := removeKeyword(host.Params, keyword)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, host.header())
+	}
+	host.Params = params
+	return nil
+}
+
+// SetParam gives keyword the arguments args in the block and returns
+// the parameter holding them
+// The first occurrence of the keyword is updated in place, keeping its
+// comments, and later occurrences are removed like RemoveParam does;
+// the parameter is appended when the block lacks the keyword
+func (host *Host) SetParam(keyword string, args ...string) *Param {
+	var param *Param
+	host.Params, param = setParam(host.Params, keyword, args)
+	return param
+}
+
+// RemoveParam removes a global parameter, see Host.RemoveParam
+func (config *Config) RemoveParam(param *Param) error {
+	return config.edit("RemoveParam "+paramLine(param), func() error {
+		params, err := removeParamNode(config.Globals, param)
+		if err != nil {
+			return err
+		}
+		config.Globals = params
+		return nil
+	})
+}
+
+// RemoveParams removes every occurrence of a global keyword, see Host.RemoveParams
+func (config *Config) RemoveParams(keyword string) error {
+	return config.edit("RemoveParams "+keyword, func() error {
+		params, err := removeKeyword(config.Globals, keyword)
+		if err != nil {
+			return fmt.Errorf("%w in globals", err)
+		}
+		config.Globals = params
+		return nil
+	})
+}
+
+// SetParam gives a global keyword the arguments args, see Host.SetParam
+func (config *Config) SetParam(keyword string, args ...string) *Param {
+	var param *Param
+	config.edit("SetParam "+strings.TrimSpace(keyword+" "+strings.Join(args, " ")), func() error {
+		config.Globals, param = setParam(config.Globals, keyword, args)
+		return nil
+	})
+	return param
+}
+
+func removeParamNode(params []*Param, param *Param) ([]*Param, error) {
+	for _, p := range params {
+		if p == param && param != nil {
+			return removeParam(params, param), nil
+		}
+	}
+	if param == nil {
+		return nil, fmt.Errorf("%w: nil", ErrNoSuchParam)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoSuchParam, param.Keyword)
+}
+
+func removeKeyword(params []*Param, keyword string) ([]*Param, error) {
+
+	key := canonicalKeyword(keyword)
+	kept := params
+	for _, param := range params {
+		if param.Keyword != "" && canonicalKeyword(param.Keyword) == key {
+			kept = removeParam(kept, param)
+		}
+	}
+
+	if len(kept) == len(params) {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchParam, keyword)
+	}
+
+	return kept, nil
+
+}
+
+func setParam(params []*Param, keyword string, args []string) ([]*Param, *Param) {
+
+	key := canonicalKeyword(keyword)
+	var set *Param
+
+	kept := params
+	for _, param := range params {
+		if param.Keyword == "" || canonicalKeyword(param.Keyword) != key {
+			continue
+		}
+		if set != nil {
+			kept = removeParam(kept, param)
+			continue
+		}
+		set = param
+		set.Keyword = keyword
+		set.Args = append([]string(nil), args...)
+	}
+
+	if set == nil {
+		set = NewParam(keyword, append([]string(nil), args...), nil)
+		kept = append(kept, set)
+	}
+
+	return kept, set
+
+}
+
+func indexOfHost(hosts []*Host, host *Host) int {
+	for i, h := range hosts {
+		if h == host && host != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// hostAddress describes a block in error messages
+func hostAddress(host *Host) string {
+	if host == nil {
+		return "nil"
+	}
+	return host.header()
+}
@@ -0,0 +1,143 @@
+package sshconfig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mutateConfigTest = `# globals
+VisualHostKey yes
+ForwardAgent no
+
+# dev box
+Host dev
+  User ubuntu
+  # old key
+  IdentityFile ~/.ssh/old
+  User root
+
+Match host *.internal
+  ProxyJump bastion
+
+# everything else
+Host *
+  User me
+`
+
+func mutateConfig(t *testing.T) *Config {
+	config, err := Parse(strings.NewReader(mutateConfigTest))
+	assert.NoError(t, err)
+	return config
+}
+
+func mutateHeaders(config *Config) []string {
+	var headers []string
+	for _, host := range config.Hosts {
+		headers = append(headers, host.header())
+	}
+	return headers
+}
+
+func TestHostOperations(t *testing.T) {
+
+	config := mutateConfig(t)
+	dev, match, wildcard := config.Hosts[0], config.Hosts[1], config.Hosts[2]
+
+	assert.NoError(t, config.MoveHost(wildcard, 0))
+	assert.Equal(t, []string{"Host *", "Host dev", "Match host *.internal"}, mutateHeaders(config))
+	assert.Equal(t, []string{"# everything else"}, config.Hosts[0].Comments)
+
+	assert.NoError(t, config.MoveHost(wildcard, 2))
+	assert.Equal(t, []string{"Host dev", "Match host *.internal", "Host *"}, mutateHeaders(config))
+
+	prod := NewHost([]string{"prod"}, nil)
+	assert.NoError(t, config.InsertHostBefore(wildcard, prod))
+	assert.NoError(t, config.InsertHostAfter(dev, NewMatch([]string{"user", "root"}, nil)))
+	assert.Equal(t, []string{"Host dev", "Match user root", "Match host *.internal", "Host prod", "Host *"}, mutateHeaders(config))
+
+	assert.ErrorIs(t, config.InsertHostAfter(dev, prod), ErrBlockExists)
+	assert.ErrorIs(t, config.InsertHostAfter(NewHost([]string{"gone"}, nil), NewHost([]string{"x"}, nil)), ErrNoSuchBlock)
+	assert.ErrorIs(t, config.MoveHost(prod, 5), ErrOutOfRange)
+
+	assert.NoError(t, config.RemoveHost(prod))
+	assert.ErrorIs(t, config.RemoveHost(prod), ErrNoSuchBlock)
+
+	assert.NoError(t, dev.RenamePattern("dev", "dev.example.com"))
+	assert.NoError(t, match.RenamePattern("*.internal", "*.corp"))
+	assert.ErrorIs(t, dev.RenamePattern("dev", "x"), ErrNoSuchPattern)
+	assert.ErrorIs(t, dev.RenamePattern("dev.example.com", ""), ErrInvalidPattern)
+	assert.Equal(t, []string{"Host dev.example.com", "Match user root", "Match host *.corp", "Host *"}, mutateHeaders(config))
+}
+
+func TestParamOperations(t *testing.T) {
+
+	config := mutateConfig(t)
+	dev := config.Hosts[0]
+
+	param := dev.SetParam("user", "deploy")
+	assert.Same(t, dev.Params[0], param)
+	assert.Equal(t, "user", param.Keyword)
+	assert.Len(t, dev.Params, 2)
+
+	assert.NoError(t, dev.RemoveParam(dev.Params[1]))
+	assert.ErrorIs(t, dev.RemoveParam(NewParam(UserKeyword, nil, nil)), ErrNoSuchParam)
+	dev.SetParam(PortKeyword, "2222")
+
+	assert.NoError(t, config.RemoveParams("forwardagent"))
+	assert.ErrorIs(t, config.RemoveParams(ForwardAgentKeyword), ErrNoSuchParam)
+	config.SetParam(VisualHostKeyKeyword, "no")
+	assert.NoError(t, config.RemoveParam(config.SetParam(BatchModeKeyword, "yes")))
+
+	assert.NoError(t, config.Hosts[1].RemoveParams(ProxyJumpKeyword))
+	assert.ErrorIs(t, config.Hosts[1].RemoveParams(ProxyJumpKeyword), ErrNoSuchParam)
+
+	expected := `# globals
+VisualHostKey no
+
+# dev box
+Host dev
+  user deploy
+  Port 2222
+
+Match host *.internal
+
+# everything else
+Host *
+  User me
+`
+	opts := DefaultFormatOptions()
+	opts.Headers = false
+	buf := &bytes.Buffer{}
+	_, err := config.WriteFormatted(buf, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestRemoveParamKeepsComments(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host dev
+  # primary user
+  User ubuntu
+  # backup user
+  User root
+  # key
+  IdentityFile ~/.ssh/dev
+  Port 22
+`))
+	assert.NoError(t, err)
+	host := config.GetHost("dev")
+
+	host.SetParam(UserKeyword, "admin")
+	assert.Equal(t, []string{"# primary user"}, host.Params[0].Comments)
+	assert.Equal(t, []string{"# backup user", "# key"}, host.GetParam(IdentityFileKeyword).Comments)
+
+	assert.NoError(t, host.RemoveParam(host.GetParam(IdentityFileKeyword)))
+	assert.Equal(t, []string{"# backup user", "# key"}, host.GetParam(PortKeyword).Comments)
+
+	assert.NoError(t, host.RemoveParams(UserKeyword))
+	assert.Equal(t, []string{"# primary user", "# backup user", "# key"}, host.GetParam(PortKeyword).Comments)
+}
@@ -0,0 +1,369 @@
+package sshconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Operations understood by Patch.Apply
+const (
+	// PatchSet replaces the arguments of the first occurrence of a
+	// keyword in a block, or of the one chosen by Occurrence, and
+	// appends the parameter when it is missing
+	PatchSet = "set"
+	// PatchAppend appends a parameter to a block
+	PatchAppend = "append"
+	// PatchRemove removes every occurrence of a keyword from a block,
+	// or only those with the given arguments, or only the one chosen
+	// by Occurrence
+	PatchRemove = "remove"
+	// PatchTest fails unless a block holds the keyword with the given
+	// arguments; without arguments it only checks the keyword is present
+	PatchTest = "test"
+	// PatchAddBlock adds an empty block after another block
+	PatchAddBlock = "add-block"
+	// PatchRemoveBlock removes a block
+	PatchRemoveBlock = "remove-block"
+	// PatchMoveBlock moves a block after another block
+	PatchMoveBlock = "move-block"
+	// PatchRename replaces the patterns of a block
+	PatchRename = "rename"
+)
+
+// Errors returned when a patch operation cannot be applied
+var (
+	ErrNoSuchBlock  = errors.New("sshconfig: no such block")
+	ErrNoSuchParam  = errors.New("sshconfig: no such parameter")
+	ErrBlockExists  = errors.New("sshconfig: block already exists")
+	ErrTestFailed   = errors.New("sshconfig: test failed")
+	ErrInvalidPatch = errors.New("sshconfig: invalid patch")
+)
+
+// PatchOperation is a single step of a Patch
+// Blocks are addressed by their first line, such as "Host dev" or
+// "Match host *.internal"; an empty address refers to the global
+// parameters. When several blocks share an address the first is used,
+// unless BlockOccurrence picks another one
+type PatchOperation struct {
+	Op      string   `json:"op"`
+	Block   string   `json:"block,omitempty"`
+	Keyword string   `json:"keyword,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// After addresses the block an added or moved block is placed
+	// after; when empty the block is placed first
+	After string `json:"after,omitempty"`
+	// Patterns are the new patterns of a renamed block
+	Patterns []string `json:"patterns,omitempty"`
+	// BlockOccurrence and AfterOccurrence count, from 1, which of the
+	// blocks sharing the Block and After addresses is meant
+	BlockOccurrence int `json:"block_occurrence,omitempty"`
+	AfterOccurrence int `json:"after_occurrence,omitempty"`
+	// Occurrence counts, from 1, which parameter with the keyword set,
+	// remove and test apply to
+	Occurrence int `json:"occurrence,omitempty"`
+}
+
+// PatchError reports which operation of a patch failed
+type PatchError struct {
+	Index     int
+	Operation PatchOperation
+	Err       error
+}
+
+func (err *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %d (%s): %v", err.Index, err.Operation.Op, err.Err)
+}
+
+func (err *PatchError) Unwrap() error {
+	return err.Err
+}
+
+// Patch is an ordered list of operations on a config, which can be
+// stored as a JSON document
+type Patch []PatchOperation
+
+// ParsePatch reads a JSON patch document
+func ParsePatch(r io.Reader) (Patch, error) {
+
+	var patch Patch
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return patch, nil
+
+}
+
+// Apply runs every operation of the patch against the config
+// Apply is atomic: the operations are first tried on a copy, and the
+// config is only changed when all of them succeed
+func (patch Patch) Apply(config *Config) error {
+
+	work, _, _ := config.duplicate()
+	if err := patch.apply(work); err != nil {
+		return err
+	}
+
+	return config.edit(fmt.Sprintf("Patch of %d operations", len(patch)), func() error {
+		return patch.apply(config)
+	})
+
+}
+
+func (patch Patch) apply(config *Config) error {
+	for i, op := range patch {
+		if err := op.apply(config); err != nil {
+			return &PatchError{Index: i, Operation: op, Err: err}
+		}
+	}
+	return nil
+}
+
+func (op PatchOperation) apply(config *Config) error {
+
+	switch op.Op {
+	case PatchAddBlock:
+		return config.addBlock(op.Block, op.BlockOccurrence, op.After, op.AfterOccurrence)
+	case PatchRemoveBlock:
+		i, err := config.findBlock(op.Block, op.BlockOccurrence)
+		if err != nil {
+			return err
+		}
+		config.Hosts = append(config.Hosts[:i:i], config.Hosts[i+1:]...)
+		return nil
+	case PatchMoveBlock:
+		i, err := config.findBlock(op.Block, op.BlockOccurrence)
+		if err != nil {
+			return err
+		}
+		host := config.Hosts[i]
+		config.Hosts = append(config.Hosts[:i:i], config.Hosts[i+1:]...)
+		return config.insertBlock(host, op.After, op.AfterOccurrence)
+	case PatchRename:
+		i, err := config.findBlock(op.Block, op.BlockOccurrence)
+		if err != nil {
+			return err
+		}
+		if len(op.Patterns) == 0 {
+			return fmt.Errorf("%w: rename needs patterns", ErrInvalidPatch)
+		}
+		if config.Hosts[i].IsMatch() {
+			config.Hosts[i].Criteria = append([]string(nil), op.Patterns...)
+		} else {
+			config.Hosts[i].Hostnames = append([]string(nil), op.Patterns...)
+		}
+		return nil
+	}
+
+	params, err := config.blockParams(op.Block, op.BlockOccurrence)
+	if err != nil {
+		return err
+	}
+	if op.Keyword == "" {
+		return fmt.Errorf("%w: %s needs a keyword", ErrInvalidPatch, op.Op)
+	}
+	if op.Occurrence < 0 {
+		return fmt.Errorf("%w: occurrence %d", ErrInvalidPatch, op.Occurrence)
+	}
+
+	// matches lists the parameters the operation applies to, in order
+	var matches []*Param
+	count := 0
+	for _, param := range *params {
+		if canonicalKeyword(param.Keyword) != canonicalKeyword(op.Keyword) {
+			continue
+		}
+		count++
+		if op.Occurrence > 0 && count != op.Occurrence {
+			continue
+		}
+		if op.Op == PatchSet || len(op.Args) == 0 || argsEqual(param.Args, op.Args) {
+			matches = append(matches, param)
+		}
+	}
+
+	switch op.Op {
+	case PatchSet:
+		if len(matches) > 0 {
+			matches[0].Args = append([]string(nil), op.Args...)
+			return nil
+		}
+		if op.Occurrence > count+1 {
+			return fmt.Errorf("%w: %s occurrence %d", ErrNoSuchParam, op.Keyword, op.Occurrence)
+		}
+		*params = append(*params, NewParam(op.Keyword, append([]string(nil), op.Args...), nil))
+	case PatchAppend:
+		*params = append(*params, NewParam(op.Keyword, append([]string(nil), op.Args...), nil))
+	case PatchRemove:
+		if len(matches) == 0 {
+			return fmt.Errorf("%w: %s", ErrNoSuchParam, op.Keyword)
+		}
+		for _, param := range matches {
+			*params = removeParam(*params, param)
+		}
+	case PatchTest:
+		if len(matches) == 0 {
+			return fmt.Errorf("%w: %s %s", ErrTestFailed, op.Keyword, strings.Join(op.Args, " "))
+		}
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+
+	return nil
+
+}
+
+// findBlock returns the index of the block with the given address,
+// the first one or the given occurrence of it
+func (config *Config) findBlock(address string, occurrence int) (int, error) {
+	key := strings.ToLower(strings.Join(strings.Fields(address), " "))
+	count := 0
+	for i, host := range config.Hosts {
+		if blockKey(host) != key {
+			continue
+		}
+		count++
+		if occurrence <= 1 || count == occurrence {
+			return i, nil
+		}
+	}
+	if occurrence > 1 {
+		return -1, fmt.Errorf("%w: %s occurrence %d", ErrNoSuchBlock, address, occurrence)
+	}
+	return -1, fmt.Errorf("%w: %s", ErrNoSuchBlock, address)
+}
+
+// blockParams returns the parameter list of the addressed block
+func (config *Config) blockParams(address string, occurrence int) (*[]*Param, error) {
+	if strings.TrimSpace(address) == "" {
+		return &config.Globals, nil
+	}
+	i, err := config.findBlock(address, occurrence)
+	if err != nil {
+		return nil, err
+	}
+	return &config.Hosts[i].Params, nil
+}
+
+// addBlock adds a block after another one
+// The block must not exist yet, or when occurrence is given, there must
+// be fewer blocks with the address than occurrence
+func (config *Config) addBlock(address string, occurrence int, after string, afterOccurrence int) error {
+
+	if _, err := config.findBlock(address, occurrence); err == nil {
+		return fmt.Errorf("%w: %s", ErrBlockExists, address)
+	}
+
+	fields := strings.Fields(address)
+	if len(fields) < 2 {
+		return fmt.Errorf("%w: %q is not a block address", ErrInvalidPatch, address)
+	}
+
+	var host *Host
+	switch {
+	case strings.EqualFold(fields[0], HostKeyword):
+		host = NewHost(fields[1:], nil)
+	case strings.EqualFold(fields[0], MatchKeyword):
+		host = NewMatch(fields[1:], nil)
+	default:
+		return fmt.Errorf("%w: %q is not a block address", ErrInvalidPatch, address)
+	}
+
+	return config.insertBlock(host, after, afterOccurrence)
+
+}
+
+// insertBlock inserts host after the addressed block, or first when after is empty
+func (config *Config) insertBlock(host *Host, after string, occurrence int) error {
+
+	at := 0
+	if after != "" {
+		i, err := config.findBlock(after, occurrence)
+		if err != nil {
+			return err
+		}
+		at = i + 1
+	}
+
+	config.Hosts = append(config.Hosts[:at:at], append([]*Host{host}, config.Hosts[at:]...)...)
+
+	return nil
+
+}
+
+// NewPatch creates a patch that turns the first config given to Diff
+// into the second one
+// Comments are not part of a diff, so they are not carried over.
+// Removals come first, last occurrence first, so that the occurrences
+// counted by Diff still hold when each operation is applied
+func NewPatch(diff *ConfigDiff) Patch {
+
+	var removals, patch Patch
+	globals := paramPatch("", 0, diff.Globals)
+
+	for _, block := range diff.Blocks {
+
+		address := block.Keyword + " " + strings.Join(block.Patterns, " ")
+		occurrence := 0
+		if block.Occurrence > 1 {
+			occurrence = block.Occurrence
+		}
+		afterOccurrence := 0
+		if block.AfterOccurrence > 1 {
+			afterOccurrence = block.AfterOccurrence
+		}
+
+		switch block.Kind {
+		case ChangeAdded:
+			patch = append(patch, PatchOperation{Op: PatchAddBlock, Block: address, BlockOccurrence: occurrence, After: block.After, AfterOccurrence: afterOccurrence})
+		case ChangeRemoved:
+			removals = append(removals, PatchOperation{Op: PatchRemoveBlock, Block: address, BlockOccurrence: occurrence})
+			continue
+		case ChangeMoved:
+			patch = append(patch, PatchOperation{Op: PatchMoveBlock, Block: address, BlockOccurrence: occurrence, After: block.After, AfterOccurrence: afterOccurrence})
+		}
+
+		patch = append(patch, paramPatch(address, occurrence, block.Params)...)
+
+	}
+
+	return append(append(globals, reversed(removals)...), patch...)
+
+}
+
+func paramPatch(address string, blockOccurrence int, changes []ParamChange) Patch {
+
+	var removals, patch Patch
+
+	for _, change := range changes {
+		op := PatchOperation{Block: address, BlockOccurrence: blockOccurrence, Keyword: change.Keyword}
+		switch change.Kind {
+		case ChangeAdded:
+			op.Op, op.Args = PatchAppend, change.NewArgs
+		case ChangeRemoved:
+			op.Op, op.Args, op.Occurrence = PatchRemove, change.OldArgs, change.Occurrence
+			removals = append(removals, op)
+			continue
+		default:
+			op.Op, op.Args, op.Occurrence = PatchSet, change.NewArgs, change.Occurrence
+		}
+		patch = append(patch, op)
+	}
+
+	return append(reversed(removals), patch...)
+
+}
+
+func reversed(patch Patch) Patch {
+	out := make(Patch, 0, len(patch))
+	for i := len(patch) - 1; i >= 0; i-- {
+		out = append(out, patch[i])
+	}
+	return out
+}
@@ -0,0 +1,166 @@
+package sshconfig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchApply(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(sshConfigTest))
+	assert.NoError(t, err)
+
+	patch, err := ParsePatch(strings.NewReader(`[
+  {"op": "test", "block": "Host dev", "keyword": "User", "args": ["ubuntu"]},
+  {"op": "set", "block": "Host dev", "keyword": "User", "args": ["ec2-user"]},
+  {"op": "remove", "block": "Host dev", "keyword": "Port"},
+  {"op": "set", "keyword": "ForwardAgent", "args": ["no"]},
+  {"op": "add-block", "block": "Host staging", "after": "Host dev"},
+  {"op": "append", "block": "Host staging", "keyword": "HostName", "args": ["10.0.0.2"]},
+  {"op": "rename", "block": "Host *.google.com *.yahoo.com", "patterns": ["*.example.com"]}
+]`))
+	assert.NoError(t, err)
+
+	assert.NoError(t, patch.Apply(config))
+
+	expected := `
+# global configuration
+VisualHostKey yes
+ForwardAgent no
+
+# host-based configuration
+
+# dev
+Host dev
+  HostName 127.0.0.1
+  User ec2-user
+
+Host staging
+  HostName 10.0.0.2
+
+Host *.example.com
+  User root
+`
+
+	var b bytes.Buffer
+	config.WriteTo(&b)
+
+	assert.Equal(t, expected, b.String())
+}
+
+func TestPatchApply_Atomic(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(sshConfigTest))
+	assert.NoError(t, err)
+
+	patch := Patch{
+		{Op: PatchSet, Block: "Host dev", Keyword: UserKeyword, Args: []string{"root"}},
+		{Op: PatchTest, Block: "Host dev", Keyword: PortKeyword, Args: []string{"2222"}},
+	}
+
+	err = patch.Apply(config)
+
+	assert.ErrorIs(t, err, ErrTestFailed)
+	assert.Equal(t, "patch operation 1 (test): sshconfig: test failed: Port 2222", err.Error())
+	assert.Equal(t, "ubuntu", config.GetHost("dev").GetParam(UserKeyword).Value())
+
+	err = Patch{{Op: PatchRemoveBlock, Block: "Host missing"}}.Apply(config)
+
+	assert.ErrorIs(t, err, ErrNoSuchBlock)
+}
+
+func TestParsePatch_Invalid(t *testing.T) {
+
+	_, err := ParsePatch(strings.NewReader(`[{"op": "set", "path": "/dev"}]`))
+
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestNewPatch(t *testing.T) {
+
+	a, err := Parse(strings.NewReader(mergeBaseTest))
+	assert.NoError(t, err)
+
+	b, err := Parse(strings.NewReader(`
+VisualHostKey no
+
+Host stale
+  User root
+
+Host dev
+  HostName 127.0.0.1
+  User admin
+  IdentityFile ~/.ssh/dev
+
+Match host *.internal
+  ProxyJump bastion
+`))
+	assert.NoError(t, err)
+
+	patch := NewPatch(Diff(a, b))
+
+	assert.NoError(t, patch.Apply(a))
+	assert.True(t, Diff(a, b).Empty(), Diff(a, b).String())
+	assert.Equal(t, []string{"stale"}, a.Hosts[0].Hostnames)
+}
+
+func TestNewPatch_Duplicates(t *testing.T) {
+
+	for _, pair := range [][2]string{
+		{
+			"Host dev\n  User a\n  User b\n  User c\n  IdentityFile x\n  IdentityFile x\n",
+			"Host dev\n  User a\n  User changed\n  IdentityFile x\n",
+		},
+		{
+			"Host dev\n  User a\n",
+			"Host dev\n  User a\n  User b\n  IdentityFile x\n  IdentityFile x\n",
+		},
+		{
+			"Host dev\n  User a\n\nHost dev\n  User b\n\nHost dev\n  User c\n\nHost web\n  User w\n",
+			"Host dev\n  User a\n\nHost dev\n  User changed\n\nHost web\n  User w\n",
+		},
+		{
+			"Host dev\n  User a\n\nHost web\n  User w\n",
+			"Host dev\n  User a\n\nHost dev\n  User b\n\nHost web\n  User w\n\nHost dev\n  User c\n\nHost stage\n  User s\n",
+		},
+		{
+			"Host dev\n  User a\n\nHost dev\n  User b\n\nHost web\n  User w\n",
+			"Host web\n  User w\n\nHost dev\n  User a\n\nHost dev\n  User b\n  Port 22\n",
+		},
+	} {
+		a, err := Parse(strings.NewReader(pair[0]))
+		assert.NoError(t, err)
+		b, err := Parse(strings.NewReader(pair[1]))
+		assert.NoError(t, err)
+
+		patch := NewPatch(Diff(a, b))
+
+		assert.NoError(t, patch.Apply(a), pair[1])
+		assert.True(t, Diff(a, b).Empty(), Diff(a, b).String())
+		assert.True(t, a.Equal(b), pair[1])
+	}
+}
+
+func TestPatchApply_Occurrence(t *testing.T) {
+
+	config, err := Parse(strings.NewReader("Host dev\n  User a\n  User b\n\nHost dev\n  User c\n"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, Patch{
+		{Op: PatchSet, Block: "Host dev", Keyword: UserKeyword, Args: []string{"z"}, Occurrence: 2},
+		{Op: PatchRemove, Block: "Host dev", BlockOccurrence: 2, Keyword: UserKeyword, Occurrence: 1},
+		{Op: PatchTest, Block: "Host dev", Keyword: UserKeyword, Args: []string{"z"}, Occurrence: 2},
+	}.Apply(config))
+	assert.Equal(t, "\nHost dev\n  User a\n  User z\n", config.Hosts[0].String())
+	assert.Empty(t, config.Hosts[1].Params)
+
+	err = Patch{{Op: PatchRemove, Block: "Host dev", Keyword: UserKeyword, Args: []string{"a"}, Occurrence: 2}}.Apply(config)
+	assert.ErrorIs(t, err, ErrNoSuchParam)
+	err = Patch{{Op: PatchRemoveBlock, Block: "Host dev", BlockOccurrence: 3}}.Apply(config)
+	assert.ErrorIs(t, err, ErrNoSuchBlock)
+	err = Patch{{Op: PatchAddBlock, Block: "Host dev", BlockOccurrence: 2}}.Apply(config)
+	assert.ErrorIs(t, err, ErrBlockExists)
+}
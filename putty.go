@@ -0,0 +1,621 @@
+package sshconfig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrInvalidRegistry is returned for registry exports that cannot be read
+var ErrInvalidRegistry = errors.New("sshconfig: invalid registry export")
+
+// puttySessionsKey is the registry key PuTTY keeps its sessions under
+const puttySessionsKey = `HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\`
+
+// puttyDefaultSettings is the session PuTTY uses for its defaults
+const puttyDefaultSettings = "Default Settings"
+
+// PuTTY proxy types, as stored in ProxyMethod
+const (
+	puttyProxyNone   = 0
+	puttyProxySOCKS4 = 1
+	puttyProxySOCKS5 = 2
+	puttyProxyHTTP   = 3
+	puttyProxySSH    = 6
+)
+
+// puttyUnmapped lists the PuTTY settings that affect how a session
+// connects or authenticates but have no ssh_config equivalent, so
+// ImportPuTTY reports them when they are set
+var puttyUnmapped = []string{
+	"AuthGSSAPI",
+	"AuthKI",
+	"AuthTIS",
+	"ChangeUsername",
+	"Cipher",
+	"GSSAPIFwd",
+	"HostKey",
+	"KEX",
+	"LocalUserName",
+	"ProxyPassword",
+	"ProxyTelnetCommand",
+	"UserNameFromEnvironment",
+}
+
+// PuTTYIssue is a setting that could not be converted between PuTTY and
+// ssh_config
+type PuTTYIssue struct {
+	// Session is the name of the PuTTY session
+	Session string
+	// Setting is the PuTTY setting or ssh keyword concerned
+	Setting string
+	Message string
+}
+
+func (issue PuTTYIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", issue.Session, issue.Setting, issue.Message)
+}
+
+// puttySession is a session read from a registry export; values are
+// strings or uint32 dwords
+type puttySession struct {
+	name   string
+	values map[string]interface{}
+}
+
+func (session *puttySession) str(name string) string {
+	s, _ := session.values[name].(string)
+	return s
+}
+
+func (session *puttySession) dword(name string) (uint32, bool) {
+	d, ok := session.values[name].(uint32)
+	return d, ok
+}
+
+// ImportPuTTY reads PuTTY sessions from a registry export, as written by
+// regedit in UTF-16 or UTF-8, and returns a config with a Host block for
+// each SSH session, named after the session
+// HostName, UserName, PortNumber, PublicKeyFile, PortForwardings,
+// RemoteCommand, Compression, AgentFwd, X11Forward, PingIntervalSecs
+// and the proxy settings are converted; SSH proxies become a ProxyJump
+// and SOCKS and HTTP proxies a ProxyCommand using nc. Settings that
+// cannot be converted, and sessions for other protocols, are reported
+// as issues; display and terminal settings are ignored
+func ImportPuTTY(r io.Reader) (*Config, []PuTTYIssue, error) {
+
+	sessions, err := readPuTTYSessions(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config := &Config{}
+	var issues []PuTTYIssue
+
+	for _, session := range sessions {
+
+		report := func(setting, format string, args ...interface{}) {
+			issues = append(issues, PuTTYIssue{Session: session.name, Setting: setting, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if session.name == puttyDefaultSettings {
+			report("", "default settings are not imported")
+			continue
+		}
+		if protocol := session.str("Protocol"); protocol != "" && protocol != "ssh" {
+			report("Protocol", "%s sessions are not supported", protocol)
+			continue
+		}
+
+		host := NewHost([]string{strings.Join(strings.Fields(session.name), "-")}, nil)
+		add := func(keyword string, args ...string) {
+			host.AddParam(NewParam(keyword, args, nil))
+		}
+
+		hostname, user := session.str("HostName"), session.str("UserName")
+		if at := strings.LastIndex(hostname, "@"); at >= 0 {
+			if user == "" {
+				user = hostname[:at]
+			}
+			hostname = hostname[at+1:]
+		}
+		if hostname != "" {
+			add(HostNameKeyword, hostname)
+		}
+		if user != "" {
+			add(UserKeyword, user)
+		}
+		if port, ok := session.dword("PortNumber"); ok && port != 0 && port != 22 {
+			add(PortKeyword, strconv.FormatUint(uint64(port), 10))
+		}
+		if key := session.str("PublicKeyFile"); key != "" {
+			add(IdentityFileKeyword, key)
+			if strings.HasSuffix(strings.ToLower(key), ".ppk") {
+				report("PublicKeyFile", "PuTTY keys must be converted with puttygen -O private-openssh before ssh can use them")
+			}
+		}
+
+		for _, forward := range strings.Split(session.str("PortForwardings"), ",") {
+			if forward == "" {
+				continue
+			}
+			keyword, args, ok := parsePuTTYForward(forward)
+			if !ok {
+				report("PortForwardings", "cannot convert %s", forward)
+				continue
+			}
+			add(keyword, args...)
+		}
+
+		if command := session.str("RemoteCommand"); command != "" {
+			add(RemoteCommandKeyword, strings.Fields(command)...)
+		}
+		for _, flag := range []struct{ setting, keyword string }{
+			{"Compression", CompressionKeyword},
+			{"AgentFwd", ForwardAgentKeyword},
+			{"X11Forward", ForwardX11Keyword},
+		} {
+			if d, ok := session.dword(flag.setting); ok && d != 0 {
+				add(flag.keyword, "yes")
+			}
+		}
+		if secs, ok := session.dword("PingIntervalSecs"); ok && secs != 0 {
+			add(ServerAliveIntervalKeyword, strconv.FormatUint(uint64(secs), 10))
+		} else if mins, ok := session.dword("PingInterval"); ok && mins != 0 {
+			add(ServerAliveIntervalKeyword, strconv.FormatUint(uint64(mins)*60, 10))
+		}
+
+		if err := importPuTTYProxy(session, add); err != nil {
+			report("ProxyMethod", "%v", err)
+		}
+
+		for _, setting := range puttyUnmapped {
+			switch value := session.values[setting].(type) {
+			case string:
+				if value != "" {
+					report(setting, "%q has no ssh_config equivalent", value)
+				}
+			case uint32:
+				if value != 0 {
+					report(setting, "%d has no ssh_config equivalent", value)
+				}
+			}
+		}
+
+		config.Hosts = append(config.Hosts, host)
+
+	}
+
+	return config, issues, nil
+
+}
+
+// importPuTTYProxy converts the proxy settings of a session
+func importPuTTYProxy(session *puttySession, add func(keyword string, args ...string)) error {
+
+	method, _ := session.dword("ProxyMethod")
+	if method == puttyProxyNone {
+		return nil
+	}
+
+	proxy := session.str("ProxyHost")
+	port, ok := session.dword("ProxyPort")
+	if !ok || port == 0 {
+		port = 80
+		if method == puttyProxySSH {
+			port = 22
+		} else if method == puttyProxySOCKS4 || method == puttyProxySOCKS5 {
+			port = 1080
+		}
+	}
+	address := fmt.Sprintf("%s:%d", proxy, port)
+
+	switch method {
+	case puttyProxySSH:
+		jump := proxy
+		if user := session.str("ProxyUsername"); user != "" {
+			jump = user + "@" + jump
+		}
+		if port != 22 {
+			jump += ":" + strconv.FormatUint(uint64(port), 10)
+		}
+		add(ProxyJumpKeyword, jump)
+	case puttyProxySOCKS4:
+		add(ProxyCommandKeyword, "nc", "-X", "4", "-x", address, "%h", "%p")
+	case puttyProxySOCKS5:
+		add(ProxyCommandKeyword, "nc", "-X", "5", "-x", address, "%h", "%p")
+	case puttyProxyHTTP:
+		add(ProxyCommandKeyword, "nc", "-X", "connect", "-x", address, "%h", "%p")
+	default:
+		return fmt.Errorf("proxy type %d has no ssh_config equivalent", method)
+	}
+
+	return nil
+
+}
+
+// parsePuTTYForward converts a PortForwardings entry, such as
+// L8080=localhost:80, 4R127.0.0.1:9000=db:5432 or D1080
+func parsePuTTYForward(forward string) (string, []string, bool) {
+
+	forward = strings.TrimLeft(forward, "46")
+	if forward == "" {
+		return "", nil, false
+	}
+
+	listen, target, _ := strings.Cut(forward[1:], "=")
+	if listen == "" {
+		return "", nil, false
+	}
+
+	switch forward[0] {
+	case 'L':
+		return LocalForwardKeyword, []string{listen, target}, target != ""
+	case 'R':
+		return RemoteForwardKeyword, []string{listen, target}, target != ""
+	case 'D':
+		return DynamicForwardKeyword, []string{listen}, true
+	}
+
+	return "", nil, false
+
+}
+
+// readPuTTYSessions reads the sessions of a registry export
+func readPuTTYSessions(r io.Reader) ([]*puttySession, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = decodeRegistryText(data)
+
+	var sessions []*puttySession
+	var session *puttySession
+	lineNumber := 0
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+
+		lineNumber++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == ';' {
+			continue
+		}
+		if lineNumber == 1 {
+			if line != "Windows Registry Editor Version 5.00" && line != "REGEDIT4" {
+				return nil, fmt.Errorf("%w: missing header", ErrInvalidRegistry)
+			}
+			continue
+		}
+
+		if line[0] == '[' {
+			key := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			session = nil
+			if name, ok := strings.CutPrefix(key, puttySessionsKey); ok && !strings.Contains(name, `\`) {
+				session = &puttySession{name: unescapePuTTYName(name), values: map[string]interface{}{}}
+				sessions = append(sessions, session)
+			}
+			continue
+		}
+		if session == nil {
+			continue
+		}
+
+		name, value, err := parseRegistryValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidRegistry, lineNumber, err)
+		}
+		session.values[name] = value
+
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+
+}
+
+// decodeRegistryText returns a registry export as UTF-8, converting the
+// UTF-16 that regedit writes
+func decodeRegistryText(data []byte) []byte {
+
+	if !bytes.HasPrefix(data, []byte{0xff, 0xfe}) {
+		return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	}
+
+	data = data[2:]
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+	}
+
+	return []byte(string(utf16.Decode(units)))
+
+}
+
+// parseRegistryValue parses a "name"="string" or "name"=dword:hex line
+func parseRegistryValue(line string) (string, interface{}, error) {
+
+	name, rest, err := readRegistryString(line)
+	if err != nil {
+		return "", nil, err
+	}
+	rest, ok := strings.CutPrefix(rest, "=")
+	if !ok {
+		return "", nil, fmt.Errorf("expected = after %q", name)
+	}
+
+	if hex, ok := strings.CutPrefix(rest, "dword:"); ok {
+		d, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid dword %s", hex)
+		}
+		return name, uint32(d), nil
+	}
+	if strings.HasPrefix(rest, `"`) {
+		value, _, err := readRegistryString(rest)
+		return name, value, err
+	}
+
+	// other types, such as hex: values, are not used by PuTTY sessions
+	return name, nil, nil
+
+}
+
+// readRegistryString reads a quoted string at the start of s and returns
+// it along with the rest of s
+func readRegistryString(s string) (string, string, error) {
+
+	if !strings.HasPrefix(s, `"`) {
+		return "", "", fmt.Errorf("expected a quoted string: %s", s)
+	}
+
+	var buf strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+			}
+			buf.WriteByte(s[i])
+		case '"':
+			return buf.String(), s[i+1:], nil
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated string: %s", s)
+
+}
+
+// unescapePuTTYName decodes the %XX escapes PuTTY uses in session keys
+func unescapePuTTYName(name string) string {
+	var buf strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '%' && i+2 < len(name) {
+			if b, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				buf.WriteByte(byte(b))
+				i += 2
+				continue
+			}
+		}
+		buf.WriteByte(name[i])
+	}
+	return buf.String()
+}
+
+// escapePuTTYName encodes a session name like PuTTY does for its keys
+func escapePuTTYName(name string) string {
+	var buf strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == ' ' || c == '\\' || c == '*' || c == '?' || c == '%' || c < ' ' || c > '~' || (c == '.' && i == 0) {
+			fmt.Fprintf(&buf, "%%%02X", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// ExportPuTTY writes a PuTTY session for every concrete host of the
+// config as a registry export, in the UTF-16 that regedit writes
+// Sessions are named after the first pattern of their block that has no
+// wildcards and hold the values ssh would use for it. Parameters that
+// PuTTY cannot express, such as a ProxyJump with several hops, are
+// reported as issues
+func (config *Config) ExportPuTTY(w io.Writer) ([]PuTTYIssue, error) {
+
+	buf := &strings.Builder{}
+	buf.WriteString("Windows Registry Editor Version 5.00\r\n")
+
+	var issues []PuTTYIssue
+	seen := map[string]bool{}
+
+	for _, block := range config.Hosts {
+
+		name := concreteHostname(block)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+
+		values, unmapped := puttyValues(name, config.Resolve(name))
+		for _, issue := range unmapped {
+			issue.Session = name
+			issues = append(issues, issue)
+		}
+
+		fmt.Fprintf(buf, "\r\n[%s%s]\r\n", puttySessionsKey, escapePuTTYName(name))
+		for _, value := range values {
+			switch v := value.value.(type) {
+			case uint32:
+				fmt.Fprintf(buf, "%q=dword:%08x\r\n", value.name, v)
+			default:
+				fmt.Fprintf(buf, "\"%s\"=\"%s\"\r\n", value.name, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v.(string)))
+			}
+		}
+
+	}
+
+	units := utf16.Encode([]rune(buf.String()))
+	out := make([]byte, 2, 2+2*len(units))
+	out[0], out[1] = 0xff, 0xfe
+	for _, u := range units {
+		out = append(out, byte(u), byte(u>>8))
+	}
+	_, err := w.Write(out)
+
+	return issues, err
+
+}
+
+// puttyValue is a registry value of a PuTTY session
+type puttyValue struct {
+	name  string
+	value interface{}
+}
+
+// puttyValues returns the session values for a resolved host, along with
+// the parameters that could not be converted
+func puttyValues(name string, resolved *Host) ([]puttyValue, []PuTTYIssue) {
+
+	values := []puttyValue{{"Protocol", "ssh"}}
+	var issues []PuTTYIssue
+	report := func(keyword, format string, args ...interface{}) {
+		issues = append(issues, PuTTYIssue{Setting: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+
+	hostname := name
+	port := uint32(22)
+	var forwards []string
+
+	for _, param := range resolved.Params {
+
+		value := strings.Join(param.Args, " ")
+		switch canonicalKeyword(param.Keyword) {
+		case "hostname":
+			hostname = expandHostName(param.Value(), name)
+		case "user":
+			values = append(values, puttyValue{"UserName", param.Value()})
+		case "port":
+			if n, err := strconv.ParseUint(param.Value(), 10, 16); err == nil {
+				port = uint32(n)
+			} else {
+				report(param.Keyword, "invalid port %s", param.Value())
+			}
+		case "identityfile":
+			if !hasPuTTYValue(values, "PublicKeyFile") {
+				values = append(values, puttyValue{"PublicKeyFile", param.Value()})
+				if !strings.HasSuffix(strings.ToLower(param.Value()), ".ppk") {
+					report(param.Keyword, "%s must be converted to a .ppk key with puttygen", param.Value())
+				}
+			} else {
+				report(param.Keyword, "PuTTY uses a single key, %s is left out", param.Value())
+			}
+		case "localforward", "remoteforward":
+			if len(param.Args) != 2 {
+				report(param.Keyword, "cannot convert %s", value)
+				continue
+			}
+			forwards = append(forwards, strings.ToUpper(param.Keyword[:1])+param.Args[0]+"="+param.Args[1])
+		case "dynamicforward":
+			forwards = append(forwards, "D"+param.Value())
+		case "remotecommand":
+			values = append(values, puttyValue{"RemoteCommand", value})
+		case "compression":
+			values = append(values, puttyValue{"Compression", puttyFlag(param.Value())})
+		case "forwardagent":
+			values = append(values, puttyValue{"AgentFwd", puttyFlag(param.Value())})
+		case "forwardx11":
+			values = append(values, puttyValue{"X11Forward", puttyFlag(param.Value())})
+		case "serveraliveinterval":
+			if d, err := parseTime(param.Value()); err == nil {
+				values = append(values, puttyValue{"PingIntervalSecs", uint32(d.Seconds())})
+			} else {
+				report(param.Keyword, "invalid interval %s", param.Value())
+			}
+		case "proxyjump":
+			proxy, err := puttyProxyJump(param.Value())
+			if err != nil {
+				report(param.Keyword, "%v", err)
+				continue
+			}
+			values = append(values, proxy...)
+		default:
+			report(param.Keyword, "%s has no PuTTY equivalent", value)
+		}
+
+	}
+
+	values = append(values,
+		puttyValue{"HostName", hostname},
+		puttyValue{"PortNumber", port},
+	)
+	if len(forwards) > 0 {
+		values = append(values, puttyValue{"PortForwardings", strings.Join(forwards, ",")})
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].name < values[j].name
+	})
+
+	return values, issues
+
+}
+
+func hasPuTTYValue(values []puttyValue, name string) bool {
+	for _, value := range values {
+		if value.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func puttyFlag(value string) uint32 {
+	if b, err := parseFlag(value); err == nil && b {
+		return 1
+	}
+	return 0
+}
+
+// puttyProxyJump converts a single-hop ProxyJump into PuTTY's SSH proxy
+func puttyProxyJump(jump string) ([]puttyValue, error) {
+
+	if strings.EqualFold(jump, "none") {
+		return nil, nil
+	}
+	if strings.Contains(jump, ",") {
+		return nil, fmt.Errorf("PuTTY proxies through a single host, cannot convert %s", jump)
+	}
+
+	jump = strings.TrimPrefix(jump, "ssh://")
+	values := []puttyValue{{"ProxyMethod", uint32(puttyProxySSH)}}
+	if at := strings.LastIndex(jump, "@"); at >= 0 {
+		values = append(values, puttyValue{"ProxyUsername", jump[:at]})
+		jump = jump[at+1:]
+	}
+	port := uint32(22)
+	if i := strings.LastIndex(jump, ":"); i >= 0 && !strings.HasSuffix(jump, "]") {
+		n, err := strconv.ParseUint(jump[i+1:], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in %s", jump)
+		}
+		jump, port = jump[:i], uint32(n)
+	}
+
+	return append(values,
+		puttyValue{"ProxyHost", strings.Trim(jump, "[]")},
+		puttyValue{"ProxyPort", port},
+	), nil
+
+}
@@ -0,0 +1,181 @@
+package sshconfig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var puttyRegTest = `Windows Registry Editor Version 5.00
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\Default%20Settings]
+"HostName"=""
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\web%20server]
+"HostName"="deploy@web.example.com"
+"PortNumber"=dword:00000898
+"Protocol"="ssh"
+"PublicKeyFile"="C:\\Users\\me\\keys\\web.ppk"
+"PortForwardings"="L8080=localhost:80,4R127.0.0.1:9000=db:5432,D1080,X9"
+"ProxyMethod"=dword:00000006
+"ProxyHost"="bastion.example.com"
+"ProxyPort"=dword:00000016
+"ProxyUsername"="jump"
+"AgentFwd"=dword:00000001
+"Compression"=dword:00000000
+"PingIntervalSecs"=dword:0000001e
+"Cipher"="aes,chacha20,WARN,3des"
+"FontHeight"=dword:0000000a
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\db]
+"HostName"="10.0.1.1"
+"UserName"="postgres"
+"PortNumber"=dword:00000016
+"ProxyMethod"=dword:00000002
+"ProxyHost"="socks.example.com"
+"RemoteCommand"="psql -l"
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\router]
+"HostName"="192.168.0.1"
+"Protocol"="telnet"
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\SshHostKeys]
+"ssh-ed25519@22:web.example.com"="0x1234"
+`
+
+func TestImportPuTTY(t *testing.T) {
+
+	config, issues, err := ImportPuTTY(strings.NewReader(puttyRegTest))
+	assert.NoError(t, err)
+
+	assert.Equal(t, `
+Host web-server
+  HostName web.example.com
+  User deploy
+  Port 2200
+  IdentityFile C:\Users\me\keys\web.ppk
+  LocalForward 8080 localhost:80
+  RemoteForward 127.0.0.1:9000 db:5432
+  DynamicForward 1080
+  ForwardAgent yes
+  ServerAliveInterval 30
+  ProxyJump jump@bastion.example.com
+
+Host db
+  HostName 10.0.1.1
+  User postgres
+  RemoteCommand psql -l
+  ProxyCommand nc -X 5 -x socks.example.com:1080 %h %p
+`, hostsString(config))
+
+	var reported []string
+	for _, issue := range issues {
+		reported = append(reported, issue.String())
+	}
+	assert.Equal(t, []string{
+		"Default Settings: : default settings are not imported",
+		"web server: PublicKeyFile: PuTTY keys must be converted with puttygen -O private-openssh before ssh can use them",
+		"web server: PortForwardings: cannot convert X9",
+		`web server: Cipher: "aes,chacha20,WARN,3des" has no ssh_config equivalent`,
+		"router: Protocol: telnet sessions are not supported",
+	}, reported)
+}
+
+func TestImportPuTTYErrors(t *testing.T) {
+
+	_, _, err := ImportPuTTY(strings.NewReader("[HKEY_CURRENT_USER]\n"))
+	assert.ErrorIs(t, err, ErrInvalidRegistry)
+
+	_, _, err = ImportPuTTY(strings.NewReader("REGEDIT4\n[" + puttySessionsKey + "web]\n\"HostName\"=\"web\n"))
+	assert.ErrorIs(t, err, ErrInvalidRegistry)
+
+	_, _, err = ImportPuTTY(strings.NewReader("REGEDIT4\n[" + puttySessionsKey + "web]\n\"PortNumber\"=dword:xyz\n"))
+	assert.ErrorIs(t, err, ErrInvalidRegistry)
+}
+
+func TestExportPuTTY(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host web *.web
+  HostName web.example.com
+  User deploy
+  IdentityFile ~/.ssh/web.ppk
+  IdentityFile ~/.ssh/other
+  LocalForward 8080 localhost:80
+  DynamicForward 1080
+  ProxyJump jump@bastion:2222
+  ServerAliveInterval 1m
+
+Host db
+  ProxyJump a,b
+  SendEnv LANG
+
+Host *
+  Port 2200
+  Compression yes
+`))
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	issues, err := config.ExportPuTTY(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xfe}, buf.Bytes()[:2])
+
+	assert.Equal(t, `Windows Registry Editor Version 5.00
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\web]
+"Compression"=dword:00000001
+"HostName"="web.example.com"
+"PingIntervalSecs"=dword:0000003c
+"PortForwardings"="L8080=localhost:80,D1080"
+"PortNumber"=dword:00000898
+"Protocol"="ssh"
+"ProxyHost"="bastion"
+"ProxyMethod"=dword:00000006
+"ProxyPort"=dword:000008ae
+"ProxyUsername"="jump"
+"PublicKeyFile"="~/.ssh/web.ppk"
+"UserName"="deploy"
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\db]
+"Compression"=dword:00000001
+"HostName"="db"
+"PortNumber"=dword:00000898
+"Protocol"="ssh"
+`, strings.ReplaceAll(string(decodeRegistryText(buf.Bytes())), "\r\n", "\n"))
+
+	var reported []string
+	for _, issue := range issues {
+		reported = append(reported, issue.String())
+	}
+	assert.Equal(t, []string{
+		"web: IdentityFile: PuTTY uses a single key, ~/.ssh/other is left out",
+		"db: ProxyJump: PuTTY proxies through a single host, cannot convert a,b",
+		"db: SendEnv: LANG has no PuTTY equivalent",
+	}, reported)
+
+	// the export reads back
+	imported, _, err := ImportPuTTY(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "jump@bastion:2222", imported.GetHost("web").GetParam(ProxyJumpKeyword).Value())
+	assert.Equal(t, "2200", imported.GetHost("db").GetParam(PortKeyword).Value())
+}
+
+func TestPuTTYNames(t *testing.T) {
+
+	assert.Equal(t, "%2Eweb%20server%25", escapePuTTYName(".web server%"))
+	assert.Equal(t, ".web server%", unescapePuTTYName("%2Eweb%20server%25"))
+}
+
+func TestExportPuTTYHostNameTokens(t *testing.T) {
+
+	config, err := Parse(strings.NewReader("Host web\n  HostName %h.example.com\n"))
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	_, err = config.ExportPuTTY(buf)
+	assert.NoError(t, err)
+	assert.Contains(t, string(decodeRegistryText(buf.Bytes())), `"HostName"="web.example.com"`)
+}
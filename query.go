@@ -0,0 +1,244 @@
+package sshconfig
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidQuery is returned for selector expressions that cannot be parsed
+var ErrInvalidQuery = errors.New("sshconfig: invalid query")
+
+// QueryResult is a node selected by Query
+// Host is nil for global parameters and Param is nil when a whole
+// block was selected
+type QueryResult struct {
+	Host  *Host
+	Param *Param
+	Pos   Position
+}
+
+// query is a parsed selector expression
+type query struct {
+	// section is "globals", "host" or "match"
+	section string
+	// block is a pattern matched against the block's patterns or criteria
+	block string
+	// keyword is a pattern matched against parameter keywords, or empty
+	// to select blocks
+	keyword string
+	// index selects an occurrence of the keyword: -1 selects all of
+	// them, and the first one is used when no index is given
+	index int
+}
+
+// Query returns the nodes selected by a path-style expression, in file order
+//
+//	globals.VisualHostKey          the global VisualHostKey
+//	host[dev].User                 User of every Host block with a dev pattern
+//	host[*.prod].IdentityFile[*]   every IdentityFile of the *.prod blocks
+//	host[dev].IdentityFile[1]      the second IdentityFile of dev
+//	match[exec].*                  every parameter of Match blocks using exec
+//	host                           every Host block
+//
+// Block selectors are wildcard patterns matched against the patterns of
+// Host blocks or the criteria of Match blocks as written, so host[*.prod]
+// selects both "Host *.prod" and "Host web.prod". Keywords are matched
+// case-insensitively and may use wildcards too. Without an index only
+// the first occurrence of a keyword in each block is selected
+func (config *Config) Query(expr string) ([]QueryResult, error) {
+
+	q, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []QueryResult
+	for _, block := range q.blocks(config) {
+		if q.keyword == "" {
+			results = append(results, QueryResult{Host: block.host, Pos: block.host.Pos})
+			continue
+		}
+		for _, param := range q.params(*block.params) {
+			results = append(results, QueryResult{Host: block.host, Param: param, Pos: param.Pos})
+		}
+	}
+
+	return results, nil
+
+}
+
+// Set replaces the arguments of every parameter selected by expr and
+// returns how many were changed
+// When a selected block lacks the keyword and the expression names a
+// single keyword without an index, the parameter is appended to it
+func (config *Config) Set(expr string, args ...string) (int, error) {
+
+	q, err := parseQuery(expr)
+	if err != nil {
+		return 0, err
+	}
+	if q.keyword == "" {
+		return 0, fmt.Errorf("%w: %q selects blocks, not parameters", ErrInvalidQuery, expr)
+	}
+
+	blocks := q.blocks(config)
+	if len(blocks) == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNoSuchBlock, expr)
+	}
+
+	count := 0
+	config.edit(strings.TrimSpace("Set "+expr+" "+strings.Join(args, " ")), func() error {
+		for _, block := range blocks {
+			params := q.params(*block.params)
+			if len(params) == 0 && q.index == 0 && !isWildcardPattern(q.keyword) {
+				*block.params = append(*block.params, NewParam(q.keyword, append([]string(nil), args...), nil))
+				count++
+				continue
+			}
+			for _, param := range params {
+				param.Args = append([]string(nil), args...)
+				count++
+			}
+		}
+		return nil
+	})
+
+	return count, nil
+
+}
+
+type queryBlock struct {
+	host   *Host
+	params *[]*Param
+}
+
+// blocks returns the blocks selected by the query, with nil hosts for globals
+func (q *query) blocks(config *Config) []queryBlock {
+
+	if q.section == "globals" {
+		return []queryBlock{{params: &config.Globals}}
+	}
+
+	var blocks []queryBlock
+	for _, host := range config.Hosts {
+		if host.IsMatch() != (q.section == "match") {
+			continue
+		}
+		if q.block != "" && !anyPatternMatches(q.block, host.patterns()) {
+			continue
+		}
+		blocks = append(blocks, queryBlock{host: host, params: &host.Params})
+	}
+
+	return blocks
+
+}
+
+// params returns the parameters of a block selected by the query
+func (q *query) params(params []*Param) []*Param {
+
+	var selected []*Param
+	occurrences := map[string]int{}
+
+	for _, param := range params {
+		if param.Keyword == "" || !matchPattern(strings.ToLower(q.keyword), strings.ToLower(param.Keyword)) {
+			continue
+		}
+		key := canonicalKeyword(param.Keyword)
+		n := occurrences[key]
+		occurrences[key]++
+		if q.index < 0 || q.index == n {
+			selected = append(selected, param)
+		}
+	}
+
+	return selected
+
+}
+
+func anyPatternMatches(pattern string, values []string) bool {
+	for _, value := range values {
+		if matchPattern(strings.ToLower(pattern), strings.ToLower(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseQuery(expr string) (*query, error) {
+
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: %q: %s", ErrInvalidQuery, expr, reason)
+	}
+
+	q := &query{}
+	rest := strings.TrimSpace(expr)
+
+	section, rest := splitSelector(rest)
+	q.section = strings.ToLower(section)
+	switch q.section {
+	case "globals":
+	case "host", "match":
+		if strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, invalid("missing ]")
+			}
+			q.block, rest = rest[1:end], rest[end+1:]
+			if q.block == "*" {
+				q.block = ""
+			}
+		}
+	default:
+		return nil, invalid("must start with globals, host or match")
+	}
+
+	if rest == "" {
+		if q.section == "globals" {
+			return nil, invalid("globals needs a keyword")
+		}
+		return q, nil
+	}
+
+	if !strings.HasPrefix(rest, ".") {
+		return nil, invalid(fmt.Sprintf("unexpected %q", rest))
+	}
+
+	q.keyword, rest = splitSelector(rest[1:])
+	if q.keyword == "" {
+		return nil, invalid("missing keyword")
+	}
+
+	if rest == "" {
+		return q, nil
+	}
+
+	if !strings.HasPrefix(rest, "[") || !strings.HasSuffix(rest, "]") {
+		return nil, invalid(fmt.Sprintf("unexpected %q", rest))
+	}
+
+	index := rest[1 : len(rest)-1]
+	if index == "*" {
+		q.index = -1
+		return q, nil
+	}
+
+	n, err := strconv.Atoi(index)
+	if err != nil || n < 0 {
+		return nil, invalid(fmt.Sprintf("bad index %q", index))
+	}
+	q.index = n
+
+	return q, nil
+
+}
+
+// splitSelector splits off the name at the start of s, up to a '.' or '['
+func splitSelector(s string) (string, string) {
+	if i := strings.IndexAny(s, ".["); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
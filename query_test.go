@@ -0,0 +1,95 @@
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var queryConfigTest = `VisualHostKey yes
+
+Host dev
+  HostName 127.0.0.1
+  User ubuntu
+  IdentityFile ~/.ssh/dev
+
+Host *.prod web.prod
+  User deploy
+  IdentityFile ~/.ssh/prod
+  IdentityFile ~/.ssh/backup
+
+Match exec "test -f /tmp/vpn"
+  ProxyJump bastion
+  User vpn
+`
+
+func queryStrings(results []QueryResult) []string {
+	var out []string
+	for _, result := range results {
+		if result.Param == nil {
+			out = append(out, result.Pos.String()+" "+result.Host.header())
+			continue
+		}
+		out = append(out, result.Pos.String()+" "+result.Param.Keyword+" "+strings.Join(result.Param.Args, " "))
+	}
+	return out
+}
+
+func TestQuery(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(queryConfigTest))
+	assert.NoError(t, err)
+
+	for expr, expected := range map[string][]string{
+		"globals.VisualHostKey":        {"1 VisualHostKey yes"},
+		"host[dev].user":               {"5 User ubuntu"},
+		"host[*.prod].IdentityFile[*]": {"10 IdentityFile ~/.ssh/prod", "11 IdentityFile ~/.ssh/backup"},
+		"host[web.prod].IdentityFile":  {"10 IdentityFile ~/.ssh/prod"},
+		"host[*].IdentityFile[1]":      {"11 IdentityFile ~/.ssh/backup"},
+		"host.User":                    {"5 User ubuntu", "9 User deploy"},
+		"match[exec].*":                {"14 ProxyJump bastion", "15 User vpn"},
+		"match":                        {`13 Match exec "test -f /tmp/vpn"`},
+		"host[nothing].User":           nil,
+	} {
+		results, err := config.Query(expr)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, expected, queryStrings(results), expr)
+	}
+}
+
+func TestQuery_Invalid(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(queryConfigTest))
+	assert.NoError(t, err)
+
+	for _, expr := range []string{"", "hosts[dev]", "host[dev", "globals", "host[dev].", "host[dev].User[x]", "host[dev]User"} {
+		_, err := config.Query(expr)
+		assert.ErrorIs(t, err, ErrInvalidQuery, expr)
+	}
+}
+
+func TestSet(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(queryConfigTest))
+	assert.NoError(t, err)
+
+	n, err := config.Set("host[*.prod].IdentityFile[*]", "~/.ssh/new")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	n, err = config.Set("host[dev].Port", "2222")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "2222", config.GetHost("dev").GetParam(PortKeyword).Value())
+
+	results, err := config.Query("host[web.prod].IdentityFile[*]")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10 IdentityFile ~/.ssh/new", "11 IdentityFile ~/.ssh/new"}, queryStrings(results))
+
+	_, err = config.Set("host[missing].User", "root")
+	assert.ErrorIs(t, err, ErrNoSuchBlock)
+
+	_, err = config.Set("host[dev]", "root")
+	assert.ErrorIs(t, err, ErrInvalidQuery)
+}
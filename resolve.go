@@ -0,0 +1,198 @@
+package sshconfig
+
+import (
+	"strings"
+)
+
+// Resolve returns the parameters ssh would use when connecting to hostname
+// Like ssh, the first value obtained for a keyword wins, except for
+// keywords such as IdentityFile where every occurrence is used.
+// The returned host holds copies, so editing it leaves the config
+// untouched; their positions still point at where each value was set
+func (config *Config) Resolve(hostname string) *Host {
+
+	resolved := NewHost([]string{hostname}, nil)
+	seen := map[string]bool{}
+	target, user := hostname, ""
+
+	add := func(params []*Param) {
+		for _, param := range params {
+			if param.Keyword == "" {
+				continue
+			}
+			key := canonicalKeyword(param.Keyword)
+			if seen[key] && !isRepeatable(param.Keyword) {
+				continue
+			}
+			seen[key] = true
+			dup := param.Clone()
+			dup.Comments = nil
+			resolved.AddParam(dup)
+			switch key {
+			case strings.ToLower(HostNameKeyword):
+				target = expandHostName(param.Value(), hostname)
+			case strings.ToLower(UserKeyword):
+				user = param.Value()
+			}
+		}
+	}
+
+	add(config.Globals)
+	for _, host := range config.Hosts {
+		if host.matches(hostname, target, user) {
+			add(host.Params)
+		}
+	}
+
+	return resolved
+
+}
+
+// Matches reports whether the block applies to hostname
+// A Host block matches when any of its patterns match and none of
+// its negated (!) patterns do. Match blocks are evaluated as if no
+// HostName or User had been configured; see matchCriteria
+func (host *Host) Matches(hostname string) bool {
+	return host.matches(hostname, hostname, "")
+}
+
+func (host *Host) matches(original, target, user string) bool {
+	if host.IsMatch() {
+		return matchCriteria(host.Criteria, original, target, user)
+	}
+	return matchPatternList(host.Hostnames, original)
+}
+
+// matchCriteria evaluates the criteria of a Match block
+// Only the all, host, originalhost and user criteria can be evaluated
+// offline; blocks using any other criteria, such as exec, never match
+func matchCriteria(criteria []string, original, target, user string) bool {
+
+	for i := 0; i < len(criteria); i++ {
+
+		criterion := strings.ToLower(criteria[i])
+		negated := strings.HasPrefix(criterion, "!")
+		criterion = strings.TrimPrefix(criterion, "!")
+
+		var matched bool
+		switch criterion {
+		case "all":
+			matched = true
+		case "host", "originalhost", "user":
+			if i+1 == len(criteria) {
+				return false
+			}
+			i++
+			patterns := strings.Split(criteria[i], ",")
+			switch criterion {
+			case "host":
+				matched = matchPatternList(patterns, target)
+			case "originalhost":
+				matched = matchPatternList(patterns, original)
+			case "user":
+				matched = user != "" && matchPatternList(patterns, user)
+			}
+		default:
+			return false
+		}
+
+		if matched == negated {
+			return false
+		}
+
+	}
+
+	return len(criteria) > 0
+
+}
+
+func matchPatternList(patterns []string, s string) bool {
+
+	matched := false
+	s = strings.ToLower(s)
+
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.ToLower(strings.TrimPrefix(pattern, "!"))
+		if !matchPattern(pattern, s) {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+
+	return matched
+
+}
+
+// matchPattern implements the ssh_config wildcards: '*' matches
+// zero or more characters and '?' matches exactly one
+func matchPattern(pattern, s string) bool {
+
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+
+	return len(s) == 0
+
+}
+
+// isWildcardPattern reports whether a host pattern can match more than one name
+func isWildcardPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?!")
+}
+
+// concreteHostname returns the first pattern of a Host block that names
+// a single host, or "" when it has none
+func concreteHostname(host *Host) string {
+	if host.IsMatch() {
+		return ""
+	}
+	for _, pattern := range host.Hostnames {
+		if !isWildcardPattern(pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// expandHostName expands the %h and %% tokens of a HostName value the
+// way ssh does, with hostname as the name given on the command line
+func expandHostName(value, hostname string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '%' && i+1 < len(value) {
+			switch value[i+1] {
+			case 'h':
+				b.WriteString(hostname)
+				i++
+				continue
+			case '%':
+				b.WriteByte('%')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var resolveConfigTest = `
+ForwardAgent no
+
+Host dev !dev.internal
+  HostName 127.0.0.1
+  User ubuntu
+  IdentityFile ~/.ssh/dev
+
+Host *.internal dev*
+  User root
+  Port 2222
+  IdentityFile ~/.ssh/internal
+
+Host *
+  ForwardAgent yes
+`
+
+func TestResolve(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(resolveConfigTest))
+
+	assert.NoError(t, err)
+
+	resolved := config.Resolve("dev")
+
+	expected := `
+Host dev
+  ForwardAgent no
+  HostName 127.0.0.1
+  User ubuntu
+  IdentityFile ~/.ssh/dev
+  Port 2222
+  IdentityFile ~/.ssh/internal
+`
+
+	assert.Equal(t, expected, resolved.String())
+}
+
+func TestResolve_CopiesParams(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(resolveConfigTest))
+
+	assert.NoError(t, err)
+
+	config.Resolve("dev").GetParam(UserKeyword).Args[0] = "changed"
+
+	assert.Equal(t, "ubuntu", config.GetHost("dev").GetParam(UserKeyword).Value())
+}
+
+func TestHostMatches(t *testing.T) {
+
+	host := NewHost([]string{"*.example.com", "db?", "!secret.example.com"}, nil)
+
+	assert.True(t, host.Matches("www.example.com"))
+	assert.True(t, host.Matches("WWW.Example.COM"))
+	assert.True(t, host.Matches("db1"))
+	assert.False(t, host.Matches("db10"))
+	assert.False(t, host.Matches("secret.example.com"))
+	assert.False(t, host.Matches("example.com"))
+}
+
+func TestResolve_Match(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host db
+  HostName db.internal
+
+Match host *.internal !user admin
+  ProxyJump bastion
+
+Match originalhost db user root
+  Port 5432
+
+Match exec "true"
+  User nobody
+`))
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "bastion", config.Resolve("db").GetParam(ProxyJumpKeyword).Value())
+	assert.Nil(t, config.Resolve("db").GetParam(PortKeyword))
+	assert.Nil(t, config.Resolve("db").GetParam(UserKeyword))
+	assert.Nil(t, config.Resolve("web").GetParam(ProxyJumpKeyword))
+}
+
+func TestExpandHostName(t *testing.T) {
+
+	assert.Equal(t, "web.example.com", expandHostName("%h.example.com", "web"))
+	assert.Equal(t, "%h-web%", expandHostName("%%h-%h%", "web"))
+}
+
+func TestResolveMatchHostNameTokens(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host web
+  HostName %h%%1.example.com
+
+Match host web%1.example.com
+  Port 2222
+`))
+	assert.NoError(t, err)
+
+	resolved := config.Resolve("web")
+	assert.Equal(t, "2222", resolved.GetParam(PortKeyword).Value())
+
+	argv, err := resolved.CommandLine()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssh", "-p", "2222", "web%1.example.com"}, argv)
+}
@@ -0,0 +1,120 @@
+package sshconfig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Errors returned by Save
+var (
+	ErrConflict         = errors.New("sshconfig: file changed since it was read")
+	ErrLockNotSupported = errors.New("sshconfig: file locking is not supported on this platform")
+)
+
+// SaveOptions controls how Save writes a config
+type SaveOptions struct {
+	// Lock holds an advisory lock on filePath + ".lock" while the file
+	// is checked and written, so cooperating writers on the same machine
+	// wait for each other instead of overwriting each other's changes
+	Lock bool
+	// Force writes the file even when it changed since it was read
+	Force bool
+	// Backups is how many previous versions of the file to keep, see
+	// WriteOptions
+	Backups int
+	// AllowSystemFiles permits writing files in /etc/ssh, which are
+	// refused with ErrSystemFile otherwise
+	AllowSystemFiles bool
+	// FS is written instead of the local filesystem when set, with
+	// filePath as a slash-separated name within it; Lock is not
+	// supported and system files are not checked
+	FS WritableFS
+}
+
+// Save writes the config to filePath, like WriteToFilepath, but first
+// checks that the file still holds Config.Source, the contents the
+// config was parsed from, and returns ErrConflict when it does not
+// A config built in code has no Source, so it can only be saved over
+// an empty or missing file. After a successful save Source holds the
+// written contents, so the config can be edited and saved again
+func (config *Config) Save(filePath string, opts SaveOptions) error {
+
+	if opts.FS != nil {
+		return config.saveFS(filePath, opts)
+	}
+
+	if !opts.AllowSystemFiles && isSystemFile(filePath) {
+		return fmt.Errorf("%w: %s", ErrSystemFile, filePath)
+	}
+
+	if opts.Lock {
+		unlock, err := lockFile(filePath + ".lock")
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	if !opts.Force {
+		current, err := os.ReadFile(filePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if sha256.Sum256(current) != sha256.Sum256(config.Source) {
+			return fmt.Errorf("%w: %s", ErrConflict, filePath)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := config.WriteTo(buf); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filePath, buf.Bytes(), WriteOptions{Backups: opts.Backups}); err != nil {
+		return err
+	}
+	config.Source = buf.Bytes()
+
+	return nil
+
+}
+
+// saveFS is Save for a WritableFS
+func (config *Config) saveFS(name string, opts SaveOptions) error {
+
+	if opts.Lock {
+		return ErrLockNotSupported
+	}
+
+	if !opts.Force {
+		current, err := fs.ReadFile(opts.FS, name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if sha256.Sum256(current) != sha256.Sum256(config.Source) {
+			return fmt.Errorf("%w: %s", ErrConflict, name)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := config.WriteTo(buf); err != nil {
+		return err
+	}
+
+	if opts.Backups > 0 {
+		if err := rotateBackupsFS(opts.FS, name, opts.Backups); err != nil {
+			return err
+		}
+	}
+	if err := opts.FS.WriteFile(name, buf.Bytes()); err != nil {
+		return err
+	}
+	config.Source = buf.Bytes()
+
+	return nil
+
+}
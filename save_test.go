@@ -0,0 +1,92 @@
+package sshconfig
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseFile(t *testing.T, path string) *Config {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	config, err := Parse(bytes.NewReader(data))
+	assert.NoError(t, err)
+	return config
+}
+
+func TestSave(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, os.WriteFile(path, []byte("Host dev\n  User ubuntu\n"), 0600))
+
+	config := parseFile(t, path)
+	config.Hosts[0].SetParam(UserKeyword, "deploy")
+	assert.NoError(t, config.Save(path, SaveOptions{}))
+	assert.Contains(t, string(parseFile(t, path).Source), "User deploy")
+
+	// saving again works, as Source now holds what was written
+	config.Hosts[0].SetParam(PortKeyword, "2222")
+	assert.NoError(t, config.Save(path, SaveOptions{Lock: true}))
+
+	// someone else edits the file
+	assert.NoError(t, os.WriteFile(path, []byte("Host dev\n  User root\n"), 0600))
+	config.Hosts[0].SetParam(PortKeyword, "22")
+	assert.ErrorIs(t, config.Save(path, SaveOptions{}), ErrConflict)
+	assert.Equal(t, "Host dev\n  User root\n", string(parseFile(t, path).Source))
+
+	assert.NoError(t, config.Save(path, SaveOptions{Force: true}))
+	assert.Contains(t, string(parseFile(t, path).Source), "Port 22")
+}
+
+func TestSaveNewFile(t *testing.T) {
+
+	dir := t.TempDir()
+
+	config := &Config{}
+	config.AddHost(NewHost([]string{"dev"}, nil))
+	assert.NoError(t, config.Save(filepath.Join(dir, "config"), SaveOptions{}))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "existing"), []byte("Host prod\n"), 0600))
+	assert.ErrorIs(t, (&Config{}).Save(filepath.Join(dir, "existing"), SaveOptions{}), ErrConflict)
+}
+
+func TestSaveLockedWriters(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, os.WriteFile(path, nil, 0600))
+
+	const writers = 8
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				config, _ := Parse(bytes.NewReader(data))
+				config.AddHost(NewHost([]string{"host" + strconv.Itoa(i)}, nil))
+				err = config.Save(path, SaveOptions{Lock: true})
+				if errors.Is(err, ErrConflict) {
+					continue
+				}
+				assert.NoError(t, err)
+				return
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Len(t, parseFile(t, path).Hosts, writers)
+}
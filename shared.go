@@ -0,0 +1,56 @@
+package sshconfig
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// SharedConfig is a config that many goroutines can read while others
+// edit it
+// Readers work on an immutable Snapshot and writers on a private copy
+// that replaces the current version in one step when they commit, so
+// readers never see a half-applied change and never wait for writers
+type SharedConfig struct {
+	// writer serialises write transactions
+	writer  sync.Mutex
+	current atomic.Pointer[Snapshot]
+}
+
+// NewSharedConfig creates a shared config holding a copy of config
+func NewSharedConfig(config *Config) *SharedConfig {
+	shared := &SharedConfig{}
+	shared.current.Store(config.Snapshot())
+	return shared
+}
+
+// Snapshot returns the current version of the config
+func (shared *SharedConfig) Snapshot() *Snapshot {
+	return shared.current.Load()
+}
+
+// View runs a read transaction: fn sees the version of the config that
+// was current when View was called, whatever writers commit meanwhile
+func (shared *SharedConfig) View(fn func(snapshot *Snapshot) error) error {
+	return fn(shared.Snapshot())
+}
+
+// Update runs a write transaction
+// fn edits a private copy of the current config, which replaces it
+// when fn returns nil. When fn returns an error or panics the copy is
+// thrown away and the config is left as it was. Write transactions run
+// one at a time, so fn always starts from the latest committed version
+func (shared *SharedConfig) Update(fn func(config *Config) error) error {
+
+	shared.writer.Lock()
+	defer shared.writer.Unlock()
+
+	work := shared.Snapshot().Edit()
+	if err := fn(work); err != nil {
+		return err
+	}
+
+	shared.current.Store(NewSnapshot(work))
+
+	return nil
+
+}
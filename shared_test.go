@@ -0,0 +1,96 @@
+package sshconfig
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSharedConfigUpdate(t *testing.T) {
+
+	config, err := Parse(strings.NewReader("Host dev\n  User ubuntu\n"))
+	assert.NoError(t, err)
+
+	shared := NewSharedConfig(config)
+	config.Hosts[0].SetParam(UserKeyword, "changed")
+	assert.Equal(t, "ubuntu", shared.Snapshot().Resolve("dev").GetParam(UserKeyword).Value())
+
+	before := shared.Snapshot()
+	assert.NoError(t, shared.Update(func(config *Config) error {
+		config.Hosts[0].SetParam(UserKeyword, "deploy")
+		return nil
+	}))
+	assert.Equal(t, "ubuntu", before.Resolve("dev").GetParam(UserKeyword).Value())
+	assert.Equal(t, "deploy", shared.Snapshot().Resolve("dev").GetParam(UserKeyword).Value())
+
+	failed := errors.New("failed")
+	assert.ErrorIs(t, shared.Update(func(config *Config) error {
+		config.Hosts[0].SetParam(UserKeyword, "root")
+		return failed
+	}), failed)
+	assert.Panics(t, func() {
+		shared.Update(func(config *Config) error {
+			config.Hosts[0].SetParam(UserKeyword, "root")
+			panic("boom")
+		})
+	})
+	assert.Equal(t, "deploy", shared.Snapshot().Resolve("dev").GetParam(UserKeyword).Value())
+
+	// the writer lock is released after a panic
+	assert.NoError(t, shared.Update(func(config *Config) error { return nil }))
+}
+
+func TestSharedConfigStress(t *testing.T) {
+
+	config, err := Parse(strings.NewReader("Host dev\n  User u0\n  Port 0\n"))
+	assert.NoError(t, err)
+	shared := NewSharedConfig(config)
+
+	const writers, readers, rounds = 4, 8, 200
+	var wg sync.WaitGroup
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				shared.Update(func(config *Config) error {
+					n, _ := strconv.Atoi(config.Hosts[0].GetParam(PortKeyword).Value())
+					n++
+					config.Hosts[0].SetParam(UserKeyword, "u"+strconv.Itoa(n))
+					config.Hosts[0].SetParam(PortKeyword, strconv.Itoa(n))
+					if i%10 == 0 {
+						return errors.New("rolled back")
+					}
+					return nil
+				})
+			}
+		}()
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				shared.View(func(snapshot *Snapshot) error {
+					host := snapshot.Resolve("dev")
+					user, port := host.GetParam(UserKeyword).Value(), host.GetParam(PortKeyword).Value()
+					if user != "u"+port {
+						t.Errorf("half-applied change: User %s, Port %s", user, port)
+					}
+					return nil
+				})
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	committed := writers * rounds * 9 / 10
+	assert.Equal(t, strconv.Itoa(committed), shared.Snapshot().Resolve("dev").GetParam(PortKeyword).Value())
+}
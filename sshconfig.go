@@ -6,10 +6,8 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	writerhelper "github.com/petems/go-sshconfig/internal"
 )
@@ -20,18 +18,31 @@ type (
 		Source  []byte
 		Globals []*Param
 		Hosts   []*Host
+
+		history *History
 	}
 	// Host struct for host entries
+	// Match blocks are stored as hosts with Criteria instead of Hostnames
 	Host struct {
 		Comments  []string
 		Hostnames []string
+		Criteria  []string
 		Params    []*Param
+		Pos       Position
 	}
 	// Param struct for parameters for configuration
 	Param struct {
 		Comments []string
 		Keyword  string
 		Args     []string
+		Pos      Position
+	}
+	// Position struct for the place a host or parameter was parsed from
+	// The zero Position means the node was created in code
+	Position struct {
+		// File is the file the node was loaded from, when known
+		File string
+		Line int
 	}
 )
 
@@ -43,6 +54,8 @@ const (
 	AddressFamilyKeyword                    = "AddressFamily"
 	BatchModeKeyword                        = "BatchMode"
 	BindAddressKeyword                      = "BindAddress"
+	BindInterfaceKeyword                    = "BindInterface"
+	CertificateFileKeyword                  = "CertificateFile"
 	CanonicalDomainsKeyword                 = "CanonicalDomains"
 	CanonicalizeFallbackLocalKeyword        = "CanonicalizeFallbackLocal"
 	CanonicalizeHostnameKeyword             = "CanonicalizeHostname"
@@ -65,6 +78,7 @@ const (
 	EscapeCharKeyword                       = "EscapeChar"
 	ExitOnForwardFailureKeyword             = "ExitOnForwardFailure"
 	FingerprintHashKeyword                  = "FingerprintHash"
+	ForkAfterAuthenticationKeyword          = "ForkAfterAuthentication"
 	ForwardAgentKeyword                     = "ForwardAgent"
 	ForwardX11Keyword                       = "ForwardX11"
 	ForwardX11TimeoutKeyword                = "ForwardX11Timeout"
@@ -75,6 +89,7 @@ const (
 	GSSAPIDelegateCredentialsKeyword        = "GSSAPIDelegateCredentials"
 	HashKnownHostsKeyword                   = "HashKnownHosts"
 	HostbasedAuthenticationKeyword          = "HostbasedAuthentication"
+	HostbasedAcceptedAlgorithmsKeyword      = "HostbasedAcceptedAlgorithms"
 	HostbasedKeyTypesKeyword                = "HostbasedKeyTypes"
 	HostKeyAlgorithmsKeyword                = "HostKeyAlgorithms"
 	HostKeyAliasKeyword                     = "HostKeyAlias"
@@ -82,10 +97,12 @@ const (
 	IdentitiesOnlyKeyword                   = "IdentitiesOnly"
 	IdentityFileKeyword                     = "IdentityFile"
 	IgnoreUnknownKeyword                    = "IgnoreUnknown"
+	IncludeKeyword                          = "Include"
 	IPQoSKeyword                            = "IPQoS"
 	KbdInteractiveAuthenticationKeyword     = "KbdInteractiveAuthentication"
 	KbdInteractiveDevicesKeyword            = "KbdInteractiveDevices"
 	KexAlgorithmsKeyword                    = "KexAlgorithms"
+	KnownHostsCommandKeyword                = "KnownHostsCommand"
 	LocalCommandKeyword                     = "LocalCommand"
 	LocalForwardKeyword                     = "LocalForward"
 	LogLevelKeyword                         = "LogLevel"
@@ -99,9 +116,13 @@ const (
 	PreferredAuthenticationsKeyword         = "PreferredAuthentications"
 	ProtocolKeyword                         = "Protocol"
 	ProxyCommandKeyword                     = "ProxyCommand"
+	ProxyJumpKeyword                        = "ProxyJump"
 	ProxyUseFdpassKeyword                   = "ProxyUseFdpass"
+	PubkeyAcceptedAlgorithmsKeyword         = "PubkeyAcceptedAlgorithms"
+	PubkeyAcceptedKeyTypesKeyword           = "PubkeyAcceptedKeyTypes"
 	PubkeyAuthenticationKeyword             = "PubkeyAuthentication"
 	RekeyLimitKeyword                       = "RekeyLimit"
+	RemoteCommandKeyword                    = "RemoteCommand"
 	RemoteForwardKeyword                    = "RemoteForward"
 	RequestTTYKeyword                       = "RequestTTY"
 	RevokedHostKeysKeyword                  = "RevokedHostKeys"
@@ -110,6 +131,9 @@ const (
 	SendEnvKeyword                          = "SendEnv"
 	ServerAliveCountMaxKeyword              = "ServerAliveCountMax"
 	ServerAliveIntervalKeyword              = "ServerAliveInterval"
+	SessionTypeKeyword                      = "SessionType"
+	SetEnvKeyword                           = "SetEnv"
+	StdinNullKeyword                        = "StdinNull"
 	StreamLocalBindMaskKeyword              = "StreamLocalBindMask"
 	StreamLocalBindUnlinkKeyword            = "StreamLocalBindUnlink"
 	StrictHostKeyCheckingKeyword            = "StrictHostKeyChecking"
@@ -118,6 +142,7 @@ const (
 	TunnelDeviceKeyword                     = "TunnelDevice"
 	UpdateHostKeysKeyword                   = "UpdateHostKeys"
 	UsePrivilegedPortKeyword                = "UsePrivilegedPort"
+	UseRoamingKeyword                       = "UseRoaming"
 	UserKeyword                             = "User"
 	UserKnownHostsFileKeyword               = "UserKnownHostsFile"
 	VerifyHostKeyDNSKeyword                 = "VerifyHostKeyDNS"
@@ -128,6 +153,16 @@ const (
 	HostConfigurationHeader   = "# host-based configuration"
 )
 
+func (pos Position) String() string {
+	if pos.Line == 0 {
+		return "-"
+	}
+	if pos.File != "" {
+		return pos.File + ":" + strconv.Itoa(pos.Line)
+	}
+	return strconv.Itoa(pos.Line)
+}
+
 // NewHost creates a new parameter based on the main objects: the hostnames and comments
 func NewHost(hostnames []string, comments []string) *Host {
 	return &Host{
@@ -136,6 +171,40 @@ func NewHost(hostnames []string, comments []string) *Host {
 	}
 }
 
+// NewMatch creates a new Match block based on its criteria and comments
+func NewMatch(criteria []string, comments []string) *Host {
+	return &Host{
+		Comments: comments,
+		Criteria: criteria,
+	}
+}
+
+// IsMatch reports whether the block is a Match block rather than a Host block
+func (host *Host) IsMatch() bool {
+	return len(host.Criteria) > 0
+}
+
+// keyword returns the keyword that opens the block
+func (host *Host) keyword() string {
+	if host.IsMatch() {
+		return MatchKeyword
+	}
+	return HostKeyword
+}
+
+// patterns returns the arguments of the line that opens the block
+func (host *Host) patterns() []string {
+	if host.IsMatch() {
+		return host.Criteria
+	}
+	return host.Hostnames
+}
+
+// header returns the line that opens the block, such as "Host dev"
+func (host *Host) header() string {
+	return host.keyword() + " " + strings.Join(host.patterns(), " ")
+}
+
 func (host *Host) String() string {
 
 	buf := &bytes.Buffer{}
@@ -150,7 +219,7 @@ func (host *Host) String() string {
 		}
 	}
 
-	fmt.Fprintf(buf, "%s %s\n", HostKeyword, strings.Join(host.Hostnames, " "))
+	fmt.Fprintln(buf, host.header())
 	for _, param := range host.Params {
 		fmt.Fprint(buf, param.HostParamString())
 	}
@@ -186,6 +255,30 @@ func NewParam(keyword string, args []string, comments []string) *Param {
 	}
 }
 
+// Clone returns a deep copy of the parameter
+func (param *Param) Clone() *Param {
+	return &Param{
+		Comments: append([]string(nil), param.Comments...),
+		Keyword:  param.Keyword,
+		Args:     append([]string(nil), param.Args...),
+		Pos:      param.Pos,
+	}
+}
+
+// Clone returns a deep copy of the host and its parameters
+func (host *Host) Clone() *Host {
+	dup := &Host{
+		Comments:  append([]string(nil), host.Comments...),
+		Hostnames: append([]string(nil), host.Hostnames...),
+		Criteria:  append([]string(nil), host.Criteria...),
+		Pos:       host.Pos,
+	}
+	for _, param := range host.Params {
+		dup.Params = append(dup.Params, param.Clone())
+	}
+	return dup
+}
+
 func (param *Param) String() string {
 
 	buf := &bytes.Buffer{}
@@ -232,9 +325,12 @@ func Parse(r io.Reader) (*Config, error) {
 		Source: data,
 	}
 
+	lineNumber := 0
+
 	sc := bufio.NewScanner(bytes.NewReader(data))
 	for sc.Scan() {
 
+		lineNumber++
 		line := strings.TrimSpace(sc.Text())
 		if len(line) == 0 {
 			continue
@@ -258,20 +354,23 @@ func Parse(r io.Reader) (*Config, error) {
 		}
 
 		param.Keyword = psc.Text()
+		param.Pos = Position{Line: lineNumber}
 
 		for psc.Scan() {
 			param.Args = append(param.Args, psc.Text())
 		}
 
-		if param.Keyword == HostKeyword {
+		if strings.EqualFold(param.Keyword, HostKeyword) || strings.EqualFold(param.Keyword, MatchKeyword) {
 			global = false
 			if host != nil {
 				config.Hosts = append(config.Hosts, host)
 			}
-			host = &Host{
-				Comments:  param.Comments,
-				Hostnames: param.Args,
+			if strings.EqualFold(param.Keyword, MatchKeyword) {
+				host = NewMatch(param.Args, param.Comments)
+			} else {
+				host = NewHost(param.Args, param.Comments)
 			}
+			host.Pos = param.Pos
 			param = &Param{}
 			continue
 		} else if global {
@@ -305,7 +404,7 @@ func (config *Config) WriteTo(w io.Writer) (int64, error) {
 	fmt.Fprintln(wc, GlobalConfigurationHeader)
 
 	for _, param := range config.Globals {
-		fmt.Fprint(w, param.String())
+		fmt.Fprint(wc, param.String())
 	}
 
 	fmt.Fprintln(wc)
@@ -315,40 +414,14 @@ func (config *Config) WriteTo(w io.Writer) (int64, error) {
 		fmt.Fprint(wc, host.String())
 	}
 
-	return wc.Written(), nil
+	return wc.Written(), wc.Err()
 }
 
 // WriteToFilepath creates a file on disk at a given path from a given sshconfig object
+// The file is replaced atomically; symlinks are written through and the
+// file keeps its mode, owner and group
 func (config *Config) WriteToFilepath(filePath string) error {
-
-	// create a tmp file in the same path with the same mode
-	tmpFilePath := filePath + "." + strconv.FormatInt(time.Now().UnixNano(), 10)
-
-	var mode os.FileMode = 0600
-	if stat, err := os.Stat(filePath); err == nil {
-		mode = stat.Mode()
-	}
-
-	file, err := os.OpenFile(tmpFilePath, os.O_CREATE|os.O_WRONLY|os.O_EXCL|os.O_SYNC, mode)
-	if err != nil {
-		return err
-	}
-
-	if _, err := config.WriteTo(file); err != nil {
-		file.Close()
-		return err
-	}
-
-	if err := file.Close(); err != nil {
-		return err
-	}
-
-	if err := os.Rename(tmpFilePath, filePath); err != nil {
-		return err
-	}
-
-	return nil
-
+	return config.WriteToFilepathWithOptions(filePath, WriteOptions{})
 }
 
 // GetParam returns a global parameter from an SSH config file
@@ -419,7 +492,10 @@ func printComments(comments []string) (commentString string) {
 
 // AddHost appends a host to a config
 func (config *Config) AddHost(host *Host) {
-	config.Hosts = append(config.Hosts, host)
+	config.edit("AddHost "+host.header(), func() error {
+		config.Hosts = append(config.Hosts, host)
+		return nil
+	})
 }
 
 // AddParam appends a parameter to a specific host
@@ -429,5 +505,8 @@ func (host *Host) AddParam(param *Param) {
 
 // AddParam appends a parameter to global parameters for a config
 func (config *Config) AddParam(param *Param) {
-	config.Globals = append(config.Globals, param)
+	config.edit("AddParam "+paramLine(param), func() error {
+		config.Globals = append(config.Globals, param)
+		return nil
+	})
 }
@@ -3,6 +3,7 @@ package sshconfig
 import (
 	"bytes"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -127,7 +128,7 @@ func TestWriteTo(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.Equal(t, sshConfigTest, b.String())
-	assert.Equal(t, writtenCount, int64(156))
+	assert.Equal(t, writtenCount, int64(174))
 }
 
 func TestWriteToWithNewParam(t *testing.T) {
@@ -162,7 +163,7 @@ Host *.google.com *.yahoo.com
 `
 
 	assert.Equal(t, expected, b.String())
-	assert.Equal(t, writtenCount, int64(158))
+	assert.Equal(t, writtenCount, int64(176))
 }
 
 func TestWriteToFilepath(t *testing.T) {
@@ -170,13 +171,36 @@ func TestWriteToFilepath(t *testing.T) {
 
 	assert.NoError(t, err)
 
-	err = config.WriteToFilepath("./example_config")
+	path := filepath.Join(t.TempDir(), "example_config")
+
+	err = config.WriteToFilepath(path)
 
 	assert.NoError(t, err)
 
-	exampleConfigContents, err := ioutil.ReadFile("./example_config")
+	exampleConfigContents, err := ioutil.ReadFile(path)
 
 	assert.NoError(t, err)
 
 	assert.Equal(t, sshConfigTest, string(exampleConfigContents))
 }
+
+func TestParseMatch(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(`
+Host dev
+  User ubuntu
+
+# internal hosts
+match host *.internal user root
+  ProxyJump bastion
+`))
+
+	assert.NoError(t, err)
+	assert.Len(t, config.Hosts, 2)
+
+	match := config.Hosts[1]
+
+	assert.True(t, match.IsMatch())
+	assert.Equal(t, []string{"host", "*.internal", "user", "root"}, match.Criteria)
+	assert.Equal(t, "\n# internal hosts\nMatch host *.internal user root\n  ProxyJump bastion\n", match.String())
+}
@@ -0,0 +1,184 @@
+package sshconfig
+
+import (
+	"strings"
+)
+
+// NodeKind tells which kind of node a Cursor is on
+type NodeKind int
+
+// Kinds of nodes visited by Walk
+const (
+	// HostNode is a Host block
+	HostNode NodeKind = iota
+	// MatchNode is a Match block
+	MatchNode
+	// ParamNode is a parameter
+	ParamNode
+	// IncludeNode is an Include parameter
+	IncludeNode
+	// CommentNode is a parameter holding only comments, such as the
+	// comments at the end of the globals
+	CommentNode
+)
+
+func (kind NodeKind) String() string {
+	switch kind {
+	case HostNode:
+		return "host"
+	case MatchNode:
+		return "match"
+	case ParamNode:
+		return "param"
+	case IncludeNode:
+		return "include"
+	case CommentNode:
+		return "comment"
+	}
+	return "unknown"
+}
+
+// WalkAction tells Walk how to carry on after visiting a node
+type WalkAction int
+
+// Actions returned by a Visitor
+const (
+	// WalkContinue visits the next node, descending into blocks
+	WalkContinue WalkAction = iota
+	// WalkSkip does not visit the parameters of the current block
+	WalkSkip
+	// WalkStop ends the walk
+	WalkStop
+)
+
+// Visitor is called by Walk for every node
+type Visitor interface {
+	Visit(cursor *Cursor) WalkAction
+}
+
+// VisitorFunc adapts a function to the Visitor interface
+type VisitorFunc func(cursor *Cursor) WalkAction
+
+// Visit calls f(cursor)
+func (f VisitorFunc) Visit(cursor *Cursor) WalkAction {
+	return f(cursor)
+}
+
+// Cursor describes the node being visited and lets the visitor edit it
+type Cursor struct {
+	// Kind is the kind of the node
+	Kind NodeKind
+	// Host is the visited block, or the block holding the visited
+	// parameter; it is nil for global parameters
+	Host *Host
+	// Param is the visited parameter, or nil when visiting a block
+	Param *Param
+
+	config  *Config
+	params  *[]*Param
+	index   int
+	deleted bool
+}
+
+// ReplaceHost replaces the visited block; its new parameters are
+// visited next. It panics when the cursor is on a parameter
+func (cursor *Cursor) ReplaceHost(host *Host) {
+	if cursor.Param != nil || cursor.deleted {
+		panic("sshconfig: ReplaceHost called on a " + cursor.Kind.String() + " node")
+	}
+	cursor.config.Hosts[cursor.index] = host
+	cursor.Host, cursor.Kind = host, blockKind(host)
+}
+
+// ReplaceParam replaces the visited parameter
+// It panics when the cursor is on a block
+func (cursor *Cursor) ReplaceParam(param *Param) {
+	if cursor.Param == nil || cursor.deleted {
+		panic("sshconfig: ReplaceParam called on a " + cursor.Kind.String() + " node")
+	}
+	(*cursor.params)[cursor.index] = param
+	cursor.Param, cursor.Kind = param, paramKind(param)
+}
+
+// Delete removes the visited node, along with its comments and, for
+// blocks, its parameters
+func (cursor *Cursor) Delete() {
+	if cursor.deleted {
+		return
+	}
+	cursor.deleted = true
+	if cursor.Param != nil {
+		*cursor.params = append((*cursor.params)[:cursor.index:cursor.index], (*cursor.params)[cursor.index+1:]...)
+		return
+	}
+	cursor.config.Hosts = append(cursor.config.Hosts[:cursor.index:cursor.index], cursor.config.Hosts[cursor.index+1:]...)
+}
+
+// Walk visits every node of the config in file order: the global
+// parameters, then each block followed by its parameters
+// Comments are part of the node they are attached to, except for
+// trailing comments, which are visited as CommentNode parameters.
+// Include directives are visited as IncludeNode parameters; the files
+// they name are not read
+func (config *Config) Walk(v Visitor) {
+
+	if walkParams(config, nil, &config.Globals, v) == WalkStop {
+		return
+	}
+
+	for i := 0; i < len(config.Hosts); i++ {
+
+		host := config.Hosts[i]
+		cursor := &Cursor{Kind: blockKind(host), Host: host, config: config, index: i}
+
+		action := v.Visit(cursor)
+		if cursor.deleted {
+			i--
+		} else if action == WalkContinue {
+			action = walkParams(config, cursor.Host, &cursor.Host.Params, v)
+		}
+		if action == WalkStop {
+			return
+		}
+
+	}
+
+}
+
+func walkParams(config *Config, host *Host, params *[]*Param, v Visitor) WalkAction {
+
+	for i := 0; i < len(*params); i++ {
+
+		param := (*params)[i]
+		cursor := &Cursor{Kind: paramKind(param), Host: host, Param: param, config: config, params: params, index: i}
+
+		action := v.Visit(cursor)
+		if cursor.deleted {
+			i--
+		}
+		if action == WalkStop {
+			return WalkStop
+		}
+
+	}
+
+	return WalkContinue
+
+}
+
+func blockKind(host *Host) NodeKind {
+	if host.IsMatch() {
+		return MatchNode
+	}
+	return HostNode
+}
+
+func paramKind(param *Param) NodeKind {
+	switch {
+	case param.Keyword == "":
+		return CommentNode
+	case strings.EqualFold(param.Keyword, IncludeKeyword):
+		return IncludeNode
+	}
+	return ParamNode
+}
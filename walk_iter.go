@@ -0,0 +1,36 @@
+//go:build go1.23
+
+package sshconfig
+
+import (
+	"iter"
+)
+
+// AllParams yields every parameter in file order, globals first, skipping
+// nodes that only hold comments
+// The config must not be edited while iterating
+func (config *Config) AllParams() iter.Seq[*Param] {
+	return func(yield func(*Param) bool) {
+		config.Walk(VisitorFunc(func(cursor *Cursor) WalkAction {
+			if cursor.Param == nil || cursor.Kind == CommentNode {
+				return WalkContinue
+			}
+			if !yield(cursor.Param) {
+				return WalkStop
+			}
+			return WalkContinue
+		}))
+	}
+}
+
+// HostsMatching yields the blocks that apply to hostname, in file order,
+// as decided by Host.Matches
+func (config *Config) HostsMatching(hostname string) iter.Seq[*Host] {
+	return func(yield func(*Host) bool) {
+		for _, host := range config.Hosts {
+			if host.Matches(hostname) && !yield(host) {
+				return
+			}
+		}
+	}
+}
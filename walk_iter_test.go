@@ -0,0 +1,37 @@
+//go:build go1.23
+
+package sshconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllParams(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(walkConfigTest))
+	assert.NoError(t, err)
+
+	var keywords []string
+	for param := range config.AllParams() {
+		keywords = append(keywords, param.Keyword)
+		if param.Keyword == ProxyJumpKeyword {
+			break
+		}
+	}
+	assert.Equal(t, []string{"Include", "VisualHostKey", "User", "IdentityFile", "ProxyJump"}, keywords)
+}
+
+func TestHostsMatching(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(walkConfigTest))
+	assert.NoError(t, err)
+
+	var headers []string
+	for host := range config.HostsMatching("dev") {
+		headers = append(headers, host.header())
+	}
+	assert.Equal(t, []string{"Host dev", "Match all", "Host *"}, headers)
+}
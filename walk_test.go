@@ -0,0 +1,127 @@
+package sshconfig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var walkConfigTest = `Include ~/.ssh/config.d/*
+VisualHostKey yes
+
+Host dev
+  User ubuntu
+  IdentityFile ~/.ssh/dev
+
+Match all
+  ProxyJump bastion
+
+Host *
+  User me
+# trailing comment
+`
+
+func TestWalk(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(walkConfigTest))
+	assert.NoError(t, err)
+
+	var visited []string
+	config.Walk(VisitorFunc(func(cursor *Cursor) WalkAction {
+		if cursor.Param == nil {
+			visited = append(visited, cursor.Kind.String()+" "+cursor.Host.header())
+		} else {
+			visited = append(visited, cursor.Kind.String()+" "+cursor.Param.Keyword)
+		}
+		return WalkContinue
+	}))
+
+	assert.Equal(t, []string{
+		"include Include",
+		"param VisualHostKey",
+		"host Host dev",
+		"param User",
+		"param IdentityFile",
+		"match Match all",
+		"param ProxyJump",
+		"host Host *",
+		"param User",
+	}, visited)
+
+	config, err = Parse(strings.NewReader("# only a comment\n"))
+	assert.NoError(t, err)
+	var kinds []NodeKind
+	config.Walk(VisitorFunc(func(cursor *Cursor) WalkAction {
+		kinds = append(kinds, cursor.Kind)
+		return WalkContinue
+	}))
+	assert.Equal(t, []NodeKind{CommentNode}, kinds)
+}
+
+func TestWalkSkipAndStop(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(walkConfigTest))
+	assert.NoError(t, err)
+
+	var visited []string
+	config.Walk(VisitorFunc(func(cursor *Cursor) WalkAction {
+		if cursor.Param != nil {
+			visited = append(visited, cursor.Param.Keyword)
+			return WalkContinue
+		}
+		switch cursor.Kind {
+		case MatchNode:
+			return WalkSkip
+		case HostNode:
+			if cursor.Host.Hostnames[0] == "*" {
+				return WalkStop
+			}
+		}
+		return WalkContinue
+	}))
+
+	assert.Equal(t, []string{"Include", "VisualHostKey", "User", "IdentityFile"}, visited)
+}
+
+func TestWalkEdit(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(walkConfigTest))
+	assert.NoError(t, err)
+
+	// redact users, drop Match blocks and the Include
+	config.Walk(VisitorFunc(func(cursor *Cursor) WalkAction {
+		switch {
+		case cursor.Kind == MatchNode, cursor.Kind == IncludeNode:
+			cursor.Delete()
+		case cursor.Kind == HostNode && cursor.Host.Hostnames[0] == "dev":
+			cursor.ReplaceHost(NewHost([]string{"staging"}, nil))
+			cursor.Host.AddParam(NewParam(UserKeyword, []string{"ubuntu"}, nil))
+		case cursor.Param != nil && cursor.Param.Keyword == UserKeyword:
+			cursor.ReplaceParam(NewParam(UserKeyword, []string{"REDACTED"}, nil))
+		}
+		return WalkContinue
+	}))
+
+	opts := DefaultFormatOptions()
+	opts.Headers = false
+	buf := &bytes.Buffer{}
+	_, err = config.WriteFormatted(buf, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, `VisualHostKey yes
+
+Host staging
+  User REDACTED
+
+Host *
+  User REDACTED
+`, buf.String())
+
+	assert.Panics(t, func() {
+		config.Walk(VisitorFunc(func(cursor *Cursor) WalkAction {
+			cursor.ReplaceHost(NewHost([]string{"dev"}, nil))
+			return WalkStop
+		}))
+	})
+}
@@ -0,0 +1,182 @@
+package sshconfig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// maxSymlinks bounds how many links are followed to find the real file
+const maxSymlinks = 40
+
+// WriteOptions controls how a config file is replaced on disk
+type WriteOptions struct {
+	// Backups is how many previous versions of the file to keep next to
+	// it, as name.bak.1 for the newest up to name.bak.N
+	Backups int
+}
+
+// WriteToFilepathWithOptions writes the config to filePath like
+// WriteToFilepath, keeping backups as set by opts
+func (config *Config) WriteToFilepathWithOptions(filePath string, opts WriteOptions) error {
+
+	buf := &bytes.Buffer{}
+	if _, err := config.WriteTo(buf); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filePath, buf.Bytes(), opts)
+
+}
+
+// writeFileAtomic replaces the file at filePath with data
+// When filePath is a symlink the file it points to is replaced and the
+// link is kept. The new file gets the mode, owner and group of the old
+// one. It is synced before it is renamed into place and its directory
+// is synced after, so a crash leaves either the old or the new file.
+// The temporary file is removed whenever the write fails
+func writeFileAtomic(filePath string, data []byte, opts WriteOptions) (err error) {
+
+	target, err := resolveSymlinks(filePath)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(target)
+
+	var mode os.FileMode = 0600
+	existing, statErr := os.Stat(target)
+	if statErr == nil {
+		mode = existing.Mode().Perm()
+	} else if !errors.Is(statErr, os.ErrNotExist) {
+		return statErr
+	}
+
+	file, err := os.CreateTemp(dir, "."+filepath.Base(target)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpFilePath := file.Name()
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tmpFilePath)
+		}
+	}()
+
+	if err = file.Chmod(mode); err != nil {
+		return err
+	}
+	if existing != nil {
+		if err = copyOwner(file, existing); err != nil {
+			return err
+		}
+	}
+	if _, err = file.Write(data); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	if existing != nil && opts.Backups > 0 {
+		if err = rotateBackups(target, opts.Backups); err != nil {
+			return err
+		}
+	}
+
+	if err = os.Rename(tmpFilePath, target); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+
+}
+
+// resolveSymlinks follows symlinks from path to the file they point at,
+// which may not exist yet
+func resolveSymlinks(path string) (string, error) {
+
+	for i := 0; i < maxSymlinks; i++ {
+		info, err := os.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return path, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return path, nil
+		}
+		link, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = link
+	}
+
+	return "", fmt.Errorf("sshconfig: too many levels of symbolic links: %s", path)
+
+}
+
+// rotateBackups shifts name.bak.1 to name.bak.2 and so on, dropping the
+// oldest, and saves the current file as name.bak.1
+// The current file is hard linked where possible so it stays in place
+// until it is replaced
+func rotateBackups(path string, count int) error {
+
+	backup := func(n int) string {
+		return path + ".bak." + strconv.Itoa(n)
+	}
+
+	if err := os.Remove(backup(count)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for n := count - 1; n >= 1; n-- {
+		if err := os.Rename(backup(n), backup(n+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := os.Link(path, backup(1)); err == nil {
+		return nil
+	}
+
+	return copyFile(path, backup(1))
+
+}
+
+func copyFile(from, to string) error {
+
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+
+}
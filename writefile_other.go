@@ -0,0 +1,17 @@
+//go:build !unix
+
+package sshconfig
+
+import (
+	"os"
+)
+
+// copyOwner does nothing where files have no unix owner
+func copyOwner(file *os.File, existing os.FileInfo) error {
+	return nil
+}
+
+// syncDir does nothing where directories cannot be synced
+func syncDir(dir string) error {
+	return nil
+}
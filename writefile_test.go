@@ -0,0 +1,102 @@
+package sshconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(data []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteToError(t *testing.T) {
+
+	config, err := Parse(strings.NewReader(sshConfigTest))
+	assert.NoError(t, err)
+
+	n, err := config.WriteTo(failingWriter{})
+	assert.EqualError(t, err, "disk full")
+	assert.Zero(t, n)
+}
+
+func TestWriteToFilepathSymlink(t *testing.T) {
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "ssh_config")
+	link := filepath.Join(dir, "config")
+	assert.NoError(t, os.Mkdir(filepath.Dir(target), 0700))
+	assert.NoError(t, os.WriteFile(target, []byte("Host old\n"), 0640))
+	assert.NoError(t, os.Symlink(filepath.Join("dotfiles", "ssh_config"), link))
+
+	config, err := Parse(strings.NewReader(sshConfigTest))
+	assert.NoError(t, err)
+	assert.NoError(t, config.WriteToFilepath(link))
+
+	info, err := os.Lstat(link)
+	assert.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+
+	data, err := os.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, sshConfigTest, string(data))
+
+	info, err = os.Stat(target)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	entries, err := os.ReadDir(filepath.Dir(target))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// a dangling link is written through as well
+	assert.NoError(t, os.Remove(target))
+	assert.NoError(t, config.WriteToFilepath(link))
+	_, err = os.Stat(target)
+	assert.NoError(t, err)
+}
+
+func TestWriteToFilepathBackups(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "config")
+	opts := WriteOptions{Backups: 2}
+
+	for _, user := range []string{"one", "two", "three", "four"} {
+		config := &Config{}
+		config.AddHost(NewHost([]string{"dev"}, nil))
+		config.Hosts[0].SetParam(UserKeyword, user)
+		assert.NoError(t, config.WriteToFilepathWithOptions(path, opts))
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(name)
+		assert.NoError(t, err)
+		return string(data)
+	}
+	assert.Contains(t, read(path), "User four")
+	assert.Contains(t, read(path+".bak.1"), "User three")
+	assert.Contains(t, read(path+".bak.2"), "User two")
+	_, err := os.Stat(path + ".bak.3")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestWriteToFilepathCleanup(t *testing.T) {
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	assert.NoError(t, os.Mkdir(path, 0700))
+
+	config, err := Parse(strings.NewReader(sshConfigTest))
+	assert.NoError(t, err)
+	assert.Error(t, config.WriteToFilepath(path))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
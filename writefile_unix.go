@@ -0,0 +1,41 @@
+//go:build unix
+
+package sshconfig
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner gives file the owner and group of existing
+func copyOwner(file *os.File, existing os.FileInfo) error {
+
+	stat, ok := existing.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	current, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if own, ok := current.Sys().(*syscall.Stat_t); ok && own.Uid == stat.Uid && own.Gid == stat.Gid {
+		return nil
+	}
+
+	return file.Chown(int(stat.Uid), int(stat.Gid))
+
+}
+
+// syncDir flushes a directory, so a rename within it survives a crash
+func syncDir(dir string) error {
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+
+}